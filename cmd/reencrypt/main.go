@@ -0,0 +1,72 @@
+// Command reencrypt re-encrypts the data stored at rest after a master key rotation:
+//
+//	go run ./cmd/reencrypt [-batch 500]
+//
+// Set the new master key as current and keep the previous ones configured, then run it. It
+// gives every user a new data key, rewrites all notes and the tasks, note links and
+// notifications derived from them with it (encrypting plaintext values as well), deletes the data keys nothing uses anymore and rewraps the remaining ones with the
+// current master key. Afterwards the previous master keys can be removed. Running it again
+// after an interruption is safe.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/joho/godotenv"
+
+	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/encryption"
+)
+
+func main() {
+	batchSize := flag.Int("batch", 500, "number of rows re-encrypted per batch")
+	flag.Parse()
+
+	if err := godotenv.Load(); err != nil {
+		log.Printf("No .env file loaded: %v", err)
+	}
+
+	config.DBInit()
+	encryption.Init()
+	if !encryption.Enabled() {
+		log.Fatalf("Encryption at rest is not configured: set ENCRYPTION_KEYS")
+	}
+
+	ctx := context.Background()
+
+	users, err := encryption.RotateDataKeys(ctx)
+	if err != nil {
+		log.Fatalf("Failed to rotate data keys: %v", err)
+	}
+	log.Printf("Created new data keys for %d users", users)
+
+	for _, table := range []struct {
+		name      string
+		reencrypt func(context.Context, int) (int, error)
+	}{
+		{"notes", encryption.ReencryptNotes},
+		{"tasks", encryption.ReencryptTasks},
+		{"note links", encryption.ReencryptNoteLinks},
+		{"notifications", encryption.ReencryptNotifications},
+	} {
+		rows, err := table.reencrypt(ctx, *batchSize)
+		if err != nil {
+			log.Fatalf("Failed after re-encrypting %d %s: %v", rows, table.name, err)
+		}
+		log.Printf("Re-encrypted %d %s", rows, table.name)
+	}
+
+	pruned, err := encryption.PruneDataKeys(ctx)
+	if err != nil {
+		log.Fatalf("Failed to delete unused data keys: %v", err)
+	}
+	log.Printf("Deleted %d unused data keys", pruned)
+
+	rewrapped, err := encryption.RewrapDataKeys(ctx)
+	if err != nil {
+		log.Fatalf("Failed to rewrap data keys: %v", err)
+	}
+	log.Printf("Rewrapped %d data keys with master key %q", rewrapped, encryption.Keys.CurrentKeyID())
+}
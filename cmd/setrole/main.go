@@ -0,0 +1,44 @@
+// Command setrole sets the role of a user, which is how the first admin is created:
+//
+//	go run ./cmd/setrole -username alice -role admin
+//
+// Afterwards admins manage roles with PUT /admin/users/:id/role.
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/joho/godotenv"
+
+	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/models"
+)
+
+func main() {
+	username := flag.String("username", "", "username of the user")
+	role := flag.String("role", models.UserRoleAdmin, "new role: user or admin")
+	flag.Parse()
+
+	if *username == "" {
+		log.Fatalf("Missing -username")
+	}
+	if *role != models.UserRoleUser && *role != models.UserRoleAdmin {
+		log.Fatalf("Role must be user or admin")
+	}
+
+	if err := godotenv.Load(); err != nil {
+		log.Printf("No .env file loaded: %v", err)
+	}
+
+	config.DBInit()
+
+	result := config.DB.Model(&models.User{}).Where("username = ?", *username).Update("role", *role)
+	if result.Error != nil {
+		log.Fatalf("Failed to update role: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		log.Fatalf("User %q not found", *username)
+	}
+	log.Printf("User %q is now %s", *username, *role)
+}
@@ -0,0 +1,14 @@
+package config
+
+import (
+	"os"
+	"strconv"
+)
+
+// GetAttachmentMaxSize returns the maximum size in bytes of an uploaded attachment.
+func GetAttachmentMaxSize() int64 {
+	if value, err := strconv.ParseInt(os.Getenv("ATTACHMENT_MAX_SIZE"), 10, 64); err == nil && value > 0 {
+		return value
+	}
+	return 10 << 20
+}
@@ -0,0 +1,14 @@
+package config
+
+import (
+	"os"
+	"strconv"
+)
+
+// GetBulkMaxOperations returns the maximum number of operations accepted in one bulk request.
+func GetBulkMaxOperations() int {
+	if value, err := strconv.Atoi(os.Getenv("BULK_MAX_OPERATIONS")); err == nil && value > 0 {
+		return value
+	}
+	return 100
+}
@@ -0,0 +1,27 @@
+package config
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"os"
+	"time"
+)
+
+// GetImageURLSecret returns the key signing image URLs. It defaults to a key derived from the JWT
+// secret, so the JWT secret itself never signs anything but tokens.
+func GetImageURLSecret() string {
+	if secret := os.Getenv("IMAGE_URL_SECRET"); secret != "" {
+		return secret
+	}
+	mac := hmac.New(sha256.New, []byte(GetJWTSecret()))
+	mac.Write([]byte("image-url"))
+	return string(mac.Sum(nil))
+}
+
+// GetImageURLTTL returns how long a signed image URL stays valid.
+func GetImageURLTTL() time.Duration {
+	if value, err := time.ParseDuration(os.Getenv("IMAGE_URL_TTL")); err == nil && value > 0 {
+		return value
+	}
+	return 15 * time.Minute
+}
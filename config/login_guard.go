@@ -0,0 +1,75 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// LoginLimits are the thresholds applied to failed logins of one username or one IP address.
+// Failures older than Window are forgotten. After DelayAfter failures the next attempt has to
+// wait Delay, doubling with every further failure, and after MaxFailures the key is locked out
+// for Lockout.
+type LoginLimits struct {
+	MaxFailures int
+	DelayAfter  int
+	Delay       time.Duration
+	Window      time.Duration
+	Lockout     time.Duration
+}
+
+// GetLoginUserLimits returns the limits of failed logins per username, read from LOGIN_USER_MAX_FAILURES,
+// LOGIN_USER_DELAY_AFTER, LOGIN_USER_DELAY, LOGIN_USER_WINDOW and LOGIN_USER_LOCKOUT.
+func GetLoginUserLimits() LoginLimits {
+	return loginLimitsFromEnv("LOGIN_USER_", LoginLimits{
+		MaxFailures: 5,
+		DelayAfter:  2,
+		Delay:       time.Second,
+		Window:      15 * time.Minute,
+		Lockout:     15 * time.Minute,
+	})
+}
+
+// GetLoginIPLimits returns the limits of failed logins per IP address, read from LOGIN_IP_MAX_FAILURES,
+// LOGIN_IP_DELAY_AFTER, LOGIN_IP_DELAY, LOGIN_IP_WINDOW and LOGIN_IP_LOCKOUT.
+func GetLoginIPLimits() LoginLimits {
+	return loginLimitsFromEnv("LOGIN_IP_", LoginLimits{
+		MaxFailures: 50,
+		DelayAfter:  10,
+		Delay:       time.Second,
+		Window:      15 * time.Minute,
+		Lockout:     15 * time.Minute,
+	})
+}
+
+func loginLimitsFromEnv(prefix string, limits LoginLimits) LoginLimits {
+	if value, err := strconv.Atoi(os.Getenv(prefix + "MAX_FAILURES")); err == nil && value > 0 {
+		limits.MaxFailures = value
+	}
+	if value, err := strconv.Atoi(os.Getenv(prefix + "DELAY_AFTER")); err == nil && value >= 0 {
+		limits.DelayAfter = value
+	}
+	for name, field := range map[string]*time.Duration{
+		"DELAY":   &limits.Delay,
+		"WINDOW":  &limits.Window,
+		"LOCKOUT": &limits.Lockout,
+	} {
+		if value, err := time.ParseDuration(os.Getenv(prefix + name)); err == nil && value > 0 {
+			*field = value
+		}
+	}
+	return limits
+}
+
+// GetNoteUnlockLimits returns the limits of wrong passphrases per locked note, read from
+// NOTE_PASSPHRASE_MAX_FAILURES, NOTE_PASSPHRASE_DELAY_AFTER, NOTE_PASSPHRASE_DELAY,
+// NOTE_PASSPHRASE_WINDOW and NOTE_PASSPHRASE_LOCKOUT.
+func GetNoteUnlockLimits() LoginLimits {
+	return loginLimitsFromEnv("NOTE_PASSPHRASE_", LoginLimits{
+		MaxFailures: 10,
+		DelayAfter:  3,
+		Delay:       time.Second,
+		Window:      15 * time.Minute,
+		Lockout:     15 * time.Minute,
+	})
+}
@@ -0,0 +1,24 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// GetNoteUnlockWindow returns how long a locked note stays readable in a session after it was unlocked.
+func GetNoteUnlockWindow() time.Duration {
+	if value, err := time.ParseDuration(os.Getenv("NOTE_UNLOCK_WINDOW")); err == nil && value > 0 {
+		return value
+	}
+	return 5 * time.Minute
+}
+
+// GetNoteLockDerivations returns how many passphrase key derivations may run at once, read from
+// NOTE_LOCK_MAX_DERIVATIONS. Each one takes 64 MiB of memory.
+func GetNoteLockDerivations() int {
+	if value, err := strconv.Atoi(os.Getenv("NOTE_LOCK_MAX_DERIVATIONS")); err == nil && value > 0 {
+		return value
+	}
+	return 4
+}
@@ -0,0 +1,20 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// GetTrustedProxies returns the addresses or CIDR ranges of the reverse proxies whose
+// X-Forwarded-For header is trusted, read from TRUSTED_PROXIES as a comma-separated list. None
+// are trusted by default, so the client IP is the address of the connection and clients
+// cannot pick the address login and rate limits count against.
+func GetTrustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"os"
+	"strconv"
+)
+
+// Quotas are the limits of a user. Zero means unlimited.
+type Quotas struct {
+	Notebooks       int64
+	Notes           int64
+	AttachmentBytes int64
+	ExportsPerDay   int64
+}
+
+// GetDefaultQuotas returns the quotas of users without limits of their own, read from
+// QUOTA_MAX_NOTEBOOKS, QUOTA_MAX_NOTES, QUOTA_MAX_ATTACHMENT_BYTES and QUOTA_MAX_EXPORTS_PER_DAY.
+func GetDefaultQuotas() Quotas {
+	return Quotas{
+		Notebooks:       quotaFromEnv("QUOTA_MAX_NOTEBOOKS"),
+		Notes:           quotaFromEnv("QUOTA_MAX_NOTES"),
+		AttachmentBytes: quotaFromEnv("QUOTA_MAX_ATTACHMENT_BYTES"),
+		ExportsPerDay:   quotaFromEnv("QUOTA_MAX_EXPORTS_PER_DAY"),
+	}
+}
+
+func quotaFromEnv(name string) int64 {
+	if value, err := strconv.ParseInt(os.Getenv(name), 10, 64); err == nil && value > 0 {
+		return value
+	}
+	return 0
+}
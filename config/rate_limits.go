@@ -0,0 +1,55 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// RateLimit allows bursts of Requests, refilled evenly over Period.
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// GetPublicRateLimit returns the rate limit of registration, login and token refresh, per IP
+// address, read from RATE_LIMIT_PUBLIC_REQUESTS and RATE_LIMIT_PUBLIC_PERIOD.
+func GetPublicRateLimit() RateLimit {
+	return rateLimitFromEnv("RATE_LIMIT_PUBLIC_", RateLimit{Requests: 60, Period: time.Minute})
+}
+
+// GetSignedURLRateLimit returns the rate limit of the routes authenticated by a signed or secret
+// URL instead of a JWT, calendar feeds and images, per IP address, read from
+// RATE_LIMIT_SIGNED_URL_REQUESTS and RATE_LIMIT_SIGNED_URL_PERIOD.
+func GetSignedURLRateLimit() RateLimit {
+	return rateLimitFromEnv("RATE_LIMIT_SIGNED_URL_", RateLimit{Requests: 300, Period: time.Minute})
+}
+
+// GetIPRateLimit returns the rate limit of the authenticated routes per IP address, checked before
+// the JWT so that invalid tokens are limited as well, read from RATE_LIMIT_IP_REQUESTS and
+// RATE_LIMIT_IP_PERIOD.
+func GetIPRateLimit() RateLimit {
+	return rateLimitFromEnv("RATE_LIMIT_IP_", RateLimit{Requests: 600, Period: time.Minute})
+}
+
+// GetAPIRateLimit returns the rate limit of the authenticated routes, per user, read from
+// RATE_LIMIT_API_REQUESTS and RATE_LIMIT_API_PERIOD.
+func GetAPIRateLimit() RateLimit {
+	return rateLimitFromEnv("RATE_LIMIT_API_", RateLimit{Requests: 300, Period: time.Minute})
+}
+
+// GetExportRateLimit returns the separate rate limit of the export routes, per user, read from
+// RATE_LIMIT_EXPORT_REQUESTS and RATE_LIMIT_EXPORT_PERIOD.
+func GetExportRateLimit() RateLimit {
+	return rateLimitFromEnv("RATE_LIMIT_EXPORT_", RateLimit{Requests: 5, Period: 10 * time.Minute})
+}
+
+func rateLimitFromEnv(prefix string, limit RateLimit) RateLimit {
+	if value, err := strconv.Atoi(os.Getenv(prefix + "REQUESTS")); err == nil && value > 0 {
+		limit.Requests = value
+	}
+	if value, err := time.ParseDuration(os.Getenv(prefix + "PERIOD")); err == nil && value > 0 {
+		limit.Period = value
+	}
+	return limit
+}
@@ -0,0 +1,14 @@
+package config
+
+import (
+	"os"
+	"time"
+)
+
+// GetReminderPollInterval returns how often the reminder scheduler looks for due reminders.
+func GetReminderPollInterval() time.Duration {
+	if value, err := time.ParseDuration(os.Getenv("REMINDER_POLL_INTERVAL")); err == nil && value > 0 {
+		return value
+	}
+	return 30 * time.Second
+}
@@ -0,0 +1,23 @@
+CREATE TABLE notifications (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    actor_id INT,
+    type VARCHAR(50) NOT NULL,
+    note_id INT,
+    message TEXT NOT NULL,
+    read BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT now(),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (actor_id) REFERENCES users(id) ON DELETE SET NULL,
+    FOREIGN KEY (note_id) REFERENCES notes(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_notifications_user_read ON notifications (user_id, read);
+
+CREATE TABLE notification_preferences (
+    user_id INT NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    PRIMARY KEY (user_id, event_type),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,18 @@
+CREATE TABLE activities (
+    id SERIAL PRIMARY KEY,
+    actor_id INT,
+    action VARCHAR(50) NOT NULL,
+    target_type VARCHAR(50) NOT NULL,
+    target_id INT,
+    notebook_id INT, -- no foreign key: entries must outlive deleted notebooks
+    before TEXT,
+    after TEXT,
+    ip VARCHAR(64),
+    user_agent TEXT,
+    created_at TIMESTAMP DEFAULT now(),
+    FOREIGN KEY (actor_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_activities_actor ON activities (actor_id, created_at);
+CREATE INDEX idx_activities_notebook ON activities (notebook_id, created_at);
+
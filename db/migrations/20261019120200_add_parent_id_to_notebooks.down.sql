@@ -0,0 +1 @@
+ALTER TABLE notebooks DROP COLUMN IF EXISTS parent_id;
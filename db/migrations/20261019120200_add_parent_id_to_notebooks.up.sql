@@ -0,0 +1,3 @@
+ALTER TABLE notebooks ADD COLUMN parent_id INT REFERENCES notebooks(id) ON DELETE CASCADE;
+
+CREATE INDEX idx_notebooks_parent ON notebooks (parent_id);
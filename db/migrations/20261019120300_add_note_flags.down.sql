@@ -0,0 +1,6 @@
+ALTER TABLE notebooks DROP COLUMN IF EXISTS archived;
+
+ALTER TABLE notes
+    DROP COLUMN IF EXISTS pinned,
+    DROP COLUMN IF EXISTS favorite,
+    DROP COLUMN IF EXISTS archived;
@@ -0,0 +1,6 @@
+ALTER TABLE notes
+    ADD COLUMN pinned BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN favorite BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN archived BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE notebooks ADD COLUMN archived BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,12 @@
+CREATE TABLE note_links (
+    id SERIAL PRIMARY KEY,
+    source_note_id INT NOT NULL,
+    target_note_id INT,
+    target_title VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT now(),
+    FOREIGN KEY (source_note_id) REFERENCES notes(id) ON DELETE CASCADE,
+    FOREIGN KEY (target_note_id) REFERENCES notes(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_note_links_source ON note_links (source_note_id);
+CREATE INDEX idx_note_links_target ON note_links (target_note_id);
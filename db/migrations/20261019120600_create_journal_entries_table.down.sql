@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS journal_entries;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS timezone,
+    DROP COLUMN IF EXISTS journal_notebook_id,
+    DROP COLUMN IF EXISTS journal_template_id;
@@ -0,0 +1,13 @@
+ALTER TABLE users
+    ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    ADD COLUMN journal_notebook_id INT REFERENCES notebooks(id) ON DELETE SET NULL,
+    ADD COLUMN journal_template_id INT REFERENCES templates(id) ON DELETE SET NULL;
+
+CREATE TABLE journal_entries (
+    user_id INT NOT NULL,
+    entry_date DATE NOT NULL,
+    note_id INT NOT NULL,
+    PRIMARY KEY (user_id, entry_date),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (note_id) REFERENCES notes(id) ON DELETE CASCADE
+);
@@ -0,0 +1,15 @@
+CREATE TABLE tasks (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    note_id INT NOT NULL,
+    line INT NOT NULL,
+    text TEXT NOT NULL,
+    done BOOLEAN NOT NULL DEFAULT FALSE,
+    due_date DATE,
+    created_at TIMESTAMP DEFAULT now(),
+    updated_at TIMESTAMP DEFAULT now(),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (note_id) REFERENCES notes(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_tasks_user_done ON tasks (user_id, done, due_date);
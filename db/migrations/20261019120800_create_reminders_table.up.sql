@@ -0,0 +1,17 @@
+CREATE TABLE reminders (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    note_id INT NOT NULL,
+    message TEXT NOT NULL DEFAULT '',
+    starts_at TIMESTAMPTZ NOT NULL,
+    rrule VARCHAR(255) NOT NULL DEFAULT '',
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    next_at TIMESTAMPTZ,
+    last_fired_at TIMESTAMPTZ,
+    created_at TIMESTAMP DEFAULT now(),
+    updated_at TIMESTAMP DEFAULT now(),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (note_id) REFERENCES notes(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_reminders_next_at ON reminders (next_at) WHERE next_at IS NOT NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE users
+    ADD COLUMN calendar_token_hash VARCHAR(64) UNIQUE;
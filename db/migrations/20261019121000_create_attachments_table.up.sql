@@ -0,0 +1,15 @@
+CREATE TABLE attachments (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    note_id INT NOT NULL,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    sha256 CHAR(64) NOT NULL,
+    created_at TIMESTAMP DEFAULT now(),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (note_id) REFERENCES notes(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_attachments_note_id ON attachments (note_id);
+CREATE INDEX idx_attachments_sha256 ON attachments (sha256);
@@ -0,0 +1,3 @@
+ALTER TABLE attachments
+    DROP COLUMN IF EXISTS width,
+    DROP COLUMN IF EXISTS height;
@@ -0,0 +1,8 @@
+CREATE TABLE user_quotas (
+    user_id INT PRIMARY KEY,
+    max_notebooks BIGINT,
+    max_notes BIGINT,
+    max_attachment_bytes BIGINT,
+    max_exports_per_day BIGINT,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS notebook_keys;
+DROP TABLE IF EXISTS user_public_keys;
+
+ALTER TABLE notes DROP COLUMN IF EXISTS key_version;
+
+ALTER TABLE notebooks
+    DROP COLUMN IF EXISTS encrypted,
+    DROP COLUMN IF EXISTS key_version;
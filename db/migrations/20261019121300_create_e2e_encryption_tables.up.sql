@@ -0,0 +1,28 @@
+ALTER TABLE notebooks
+    ADD COLUMN encrypted BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN key_version INT NOT NULL DEFAULT 0;
+
+ALTER TABLE notes
+    ADD COLUMN key_version INT NOT NULL DEFAULT 0;
+
+CREATE TABLE user_public_keys (
+    user_id INT PRIMARY KEY,
+    algorithm VARCHAR(64) NOT NULL,
+    public_key TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT now(),
+    updated_at TIMESTAMP DEFAULT now(),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE notebook_keys (
+    notebook_id INT NOT NULL,
+    user_id INT NOT NULL,
+    key_version INT NOT NULL,
+    wrapped_key TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT now(),
+    PRIMARY KEY (notebook_id, user_id, key_version),
+    FOREIGN KEY (notebook_id) REFERENCES notebooks(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_notebook_keys_user_id ON notebook_keys (user_id);
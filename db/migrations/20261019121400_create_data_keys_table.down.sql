@@ -0,0 +1,3 @@
+ALTER TABLE notes ALTER COLUMN title TYPE VARCHAR(255);
+
+DROP TABLE IF EXISTS data_keys;
@@ -0,0 +1,13 @@
+CREATE TABLE data_keys (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    master_key_id VARCHAR(255) NOT NULL,
+    wrapped_key BYTEA NOT NULL,
+    created_at TIMESTAMP DEFAULT now(),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_data_keys_user_id ON data_keys (user_id);
+
+-- Encrypted titles are longer than the plaintext ones
+ALTER TABLE notes ALTER COLUMN title TYPE TEXT;
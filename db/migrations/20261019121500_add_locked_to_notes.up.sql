@@ -0,0 +1 @@
+ALTER TABLE notes ADD COLUMN locked BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,45 @@
+CREATE TABLE workspaces (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    personal_user_id INT UNIQUE,
+    created_at TIMESTAMP DEFAULT now(),
+    updated_at TIMESTAMP DEFAULT now(),
+    FOREIGN KEY (personal_user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE workspace_members (
+    workspace_id INT NOT NULL,
+    user_id INT NOT NULL,
+    role VARCHAR(16) NOT NULL DEFAULT 'member',
+    created_at TIMESTAMP DEFAULT now(),
+    PRIMARY KEY (workspace_id, user_id),
+    FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_workspace_members_user_id ON workspace_members (user_id);
+
+CREATE TABLE workspace_invitations (
+    id SERIAL PRIMARY KEY,
+    workspace_id INT NOT NULL,
+    user_id INT NOT NULL,
+    invited_by INT,
+    role VARCHAR(16) NOT NULL DEFAULT 'member',
+    created_at TIMESTAMP DEFAULT now(),
+    UNIQUE (workspace_id, user_id),
+    FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (invited_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_workspace_invitations_user_id ON workspace_invitations (user_id);
+
+-- Existing notebooks move into the personal workspace of their owner
+INSERT INTO workspaces (name, personal_user_id) SELECT 'Personal', id FROM users;
+INSERT INTO workspace_members (workspace_id, user_id, role) SELECT id, personal_user_id, 'admin' FROM workspaces;
+
+ALTER TABLE notebooks ADD COLUMN workspace_id INT REFERENCES workspaces(id) ON DELETE CASCADE;
+UPDATE notebooks SET workspace_id = workspaces.id FROM workspaces WHERE workspaces.personal_user_id = notebooks.user_id;
+ALTER TABLE notebooks ALTER COLUMN workspace_id SET NOT NULL;
+
+CREATE INDEX idx_notebooks_workspace_id ON notebooks (workspace_id);
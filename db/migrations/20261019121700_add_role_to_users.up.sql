@@ -0,0 +1,6 @@
+ALTER TABLE users
+    ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'user',
+    ADD COLUMN disabled BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN sessions_revoked_at TIMESTAMP;
+
+-- The first admins are promoted with: go run ./cmd/setrole -username <name> -role admin
@@ -0,0 +1,9 @@
+-- Failed logins per username or IP address, used when LOGIN_GUARD_STORE=postgres
+CREATE TABLE login_attempts (
+    key VARCHAR(255) PRIMARY KEY,
+    failures INT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL,
+    blocked_until TIMESTAMP
+);
+
+CREATE INDEX idx_login_attempts_expires_at ON login_attempts (expires_at);
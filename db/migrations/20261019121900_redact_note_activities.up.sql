@@ -0,0 +1,2 @@
+-- Superseded: activities are append-only, so the title and content older versions copied into
+-- note snapshots are left out when the activity log is read instead
@@ -0,0 +1 @@
+ALTER TABLE note_links DROP COLUMN IF EXISTS user_id;
@@ -0,0 +1,6 @@
+-- The owner of the source note, whose data key encrypts target_title at rest
+ALTER TABLE note_links ADD COLUMN user_id INT REFERENCES users(id) ON DELETE CASCADE;
+
+UPDATE note_links SET user_id = notes.user_id FROM notes WHERE notes.id = note_links.source_note_id;
+
+ALTER TABLE note_links ALTER COLUMN user_id SET NOT NULL;
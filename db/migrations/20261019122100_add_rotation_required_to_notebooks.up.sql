@@ -0,0 +1 @@
+ALTER TABLE notebooks ADD COLUMN rotation_required BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,2 @@
+DELETE FROM login_attempts WHERE LENGTH(key) > 255;
+ALTER TABLE login_attempts ALTER COLUMN key TYPE VARCHAR(255);
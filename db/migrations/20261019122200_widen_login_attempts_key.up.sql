@@ -0,0 +1,2 @@
+-- Keys embed the submitted username, which may be longer than 255 characters
+ALTER TABLE login_attempts ALTER COLUMN key TYPE TEXT;
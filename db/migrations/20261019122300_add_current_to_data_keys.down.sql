@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_data_keys_current;
+ALTER TABLE data_keys DROP COLUMN IF EXISTS current;
@@ -0,0 +1,7 @@
+-- Each user has at most one current data key, the newest one
+ALTER TABLE data_keys ADD COLUMN current BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE data_keys SET current = TRUE
+WHERE id IN (SELECT MAX(id) FROM data_keys GROUP BY user_id);
+
+CREATE UNIQUE INDEX idx_data_keys_current ON data_keys (user_id) WHERE current;
@@ -0,0 +1,129 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func TestKeyRing(t *testing.T) {
+	ctx := context.Background()
+	ring, err := NewKeyRing("new", map[string][]byte{"old": testKey(1), "new": testKey(2)})
+	require.NoError(t, err)
+	assert.Equal(t, "new", ring.CurrentKeyID())
+
+	dataKey := testKey(9)
+	wrapped, err := ring.Wrap(ctx, "old", dataKey)
+	require.NoError(t, err)
+	assert.NotContains(t, string(wrapped), string(dataKey))
+
+	unwrapped, err := ring.Unwrap(ctx, "old", wrapped)
+	require.NoError(t, err)
+	assert.Equal(t, dataKey, unwrapped)
+
+	// The master key ID is authenticated, and tampering is detected
+	_, err = ring.Unwrap(ctx, "new", wrapped)
+	assert.Error(t, err)
+	wrapped[len(wrapped)-1] ^= 1
+	_, err = ring.Unwrap(ctx, "old", wrapped)
+	assert.Error(t, err)
+
+	_, err = ring.Unwrap(ctx, "gone", wrapped)
+	assert.ErrorIs(t, err, ErrUnknownMasterKey)
+}
+
+func TestNewKeyRingValidation(t *testing.T) {
+	_, err := NewKeyRing("missing", map[string][]byte{"a": testKey(1)})
+	assert.Error(t, err)
+	_, err = NewKeyRing("a", map[string][]byte{"a": []byte("short")})
+	assert.Error(t, err)
+	_, err = NewKeyRing("a:b", map[string][]byte{"a:b": testKey(1)})
+	assert.Error(t, err)
+}
+
+func TestLoadKeyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	content := `{"current": "2026-10", "keys": {"2026-04": "` + base64.StdEncoding.EncodeToString(testKey(1)) +
+		`", "2026-10": "` + base64.StdEncoding.EncodeToString(testKey(2)) + `"}}`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	ring, err := LoadKeyFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "2026-10", ring.CurrentKeyID())
+	assert.Len(t, ring.keys, 2)
+}
+
+func TestKeysFromEnv(t *testing.T) {
+	t.Setenv("ENCRYPTION_KEYS", "")
+	keys, err := keysFromEnv()
+	require.NoError(t, err)
+	assert.Nil(t, keys)
+
+	t.Setenv("ENCRYPTION_KEYS", "config")
+	t.Setenv("ENCRYPTION_MASTER_KEY_ID", "k2")
+	t.Setenv("ENCRYPTION_MASTER_KEY", base64.StdEncoding.EncodeToString(testKey(2)))
+	t.Setenv("ENCRYPTION_PREVIOUS_MASTER_KEYS", "k1:"+base64.StdEncoding.EncodeToString(testKey(1)))
+	keys, err = keysFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "k2", keys.CurrentKeyID())
+	assert.Len(t, keys.(*KeyRing).keys, 2)
+
+	t.Setenv("ENCRYPTION_KEYS", "vault")
+	_, err = keysFromEnv()
+	assert.Error(t, err)
+}
+
+func TestDecryptPlaintext(t *testing.T) {
+	ctx := context.Background()
+
+	plaintext, err := Decrypt(ctx, "notes.title", "Meeting notes")
+	require.NoError(t, err)
+	assert.Equal(t, "Meeting notes", plaintext)
+
+	// Encrypted values cannot be read without master keys
+	_, err = Decrypt(ctx, "notes.title", prefix+"1:AAAA")
+	assert.Error(t, err)
+}
+
+func TestDecryptBindsColumn(t *testing.T) {
+	ctx := context.Background()
+	ring, err := NewKeyRing("k", map[string][]byte{"k": testKey(1)})
+	require.NoError(t, err)
+	Keys = ring
+	t.Cleanup(func() { Keys = nil })
+
+	aead, err := newAEAD(testKey(7))
+	require.NoError(t, err)
+	dataKeys.Store(uint(7), aead)
+	t.Cleanup(func() { dataKeys.Delete(uint(7)) })
+
+	sealed, err := seal(aead, []byte("Secret plans"), []byte(prefix+"7:notes.title"))
+	require.NoError(t, err)
+	value := prefix + "7:" + base64.RawStdEncoding.EncodeToString(sealed)
+	assert.True(t, IsEncrypted(value))
+
+	plaintext, err := Decrypt(ctx, "notes.title", value)
+	require.NoError(t, err)
+	assert.Equal(t, "Secret plans", plaintext)
+
+	// A value copied into another column does not decrypt there
+	_, err = Decrypt(ctx, "notes.content", value)
+	assert.Error(t, err)
+
+	// Values written before columns were bound are still read
+	sealed, err = seal(aead, []byte("Old plans"), []byte(legacyPrefix+"7"))
+	require.NoError(t, err)
+	plaintext, err = Decrypt(ctx, "tasks.text", legacyPrefix+"7:"+base64.RawStdEncoding.EncodeToString(sealed))
+	require.NoError(t, err)
+	assert.Equal(t, "Old plans", plaintext)
+}
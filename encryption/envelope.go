@@ -0,0 +1,221 @@
+package encryption
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/models"
+)
+
+// prefix starts every encrypted value: "enc:v2:<data key ID>:<base64 nonce and ciphertext>".
+// The additional data is "enc:v2:<data key ID>:<table>.<column>", so a value only decrypts in
+// the column it was written to. Values without a prefix are plaintext, written before encryption
+// at rest was enabled.
+const prefix = "enc:v2:"
+
+// legacyPrefix starts the values written before the column was bound, whose additional data is
+// only "enc:v1:<data key ID>". They are still read, and rewritten with prefix by ReencryptNotes.
+const legacyPrefix = "enc:v1:"
+
+var (
+	// Keys are the master keys wrapping the data keys, set up by Init. Encryption at rest is
+	// disabled while it is nil.
+	Keys MasterKeys
+
+	// dataKeys caches the unwrapped data keys by ID, as cipher.AEAD.
+	dataKeys sync.Map
+)
+
+// Init configures the master keys from the environment, see keysFromEnv.
+func Init() {
+	var err error
+	Keys, err = keysFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure encryption at rest: %v", err)
+	}
+}
+
+// Enabled reports whether new values are encrypted at rest.
+func Enabled() bool {
+	return Keys != nil
+}
+
+// IsEncrypted reports whether the stored value is encrypted.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix) || strings.HasPrefix(value, legacyPrefix)
+}
+
+// Encrypt encrypts the value of the column, named "<table>.<column>", with the current data key
+// of the user, created on first use.
+func Encrypt(ctx context.Context, userID uint, column, plaintext string) (string, error) {
+	if Keys == nil {
+		return "", errors.New("encryption at rest is not configured")
+	}
+
+	key, err := currentDataKey(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	aead, err := dataKeyAEAD(ctx, key)
+	if err != nil {
+		return "", err
+	}
+
+	header := prefix + strconv.FormatUint(uint64(key.ID), 10)
+	sealed, err := seal(aead, []byte(plaintext), []byte(header+":"+column))
+	if err != nil {
+		return "", err
+	}
+	return header + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt returns the plaintext of a value stored in the column, named "<table>.<column>".
+// Plaintext values are returned as they are.
+func Decrypt(ctx context.Context, column, value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	if Keys == nil {
+		return "", errors.New("value is encrypted but encryption at rest is not configured")
+	}
+
+	header := prefix
+	if strings.HasPrefix(value, legacyPrefix) {
+		header = legacyPrefix
+	}
+	idText, encoded, ok := strings.Cut(value[len(header):], ":")
+	id, err := strconv.ParseUint(idText, 10, 32)
+	if !ok || err != nil {
+		return "", errors.New("malformed encrypted value")
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", errors.New("malformed encrypted value")
+	}
+
+	aead, err := dataKeyByID(ctx, uint(id))
+	if err != nil {
+		return "", err
+	}
+	additionalData := header + idText
+	if header == prefix {
+		additionalData += ":" + column
+	}
+	plaintext, err := open(aead, sealed, []byte(additionalData))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt with data key %d: %w", id, err)
+	}
+	return string(plaintext), nil
+}
+
+// NewDataKey generates a data key for the user, wrapped by the current master key. It replaces
+// the user's current data key.
+func NewDataKey(ctx context.Context, userID uint) (models.DataKey, error) {
+	var key models.DataKey
+	err := config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.DataKey{}).Where("user_id = ? AND current", userID).Update("current", false).Error; err != nil {
+			return err
+		}
+		var err error
+		key, err = createDataKey(ctx, tx, userID)
+		return err
+	})
+	return key, err
+}
+
+// currentDataKey returns the current data key of the user. It is looked up on every write
+// rather than cached, so that rotated data keys are used by all backends right away.
+//
+// The first data key is created on its own rather than in the transaction of the write needing
+// it, so it stays the current key even if that write is rolled back. Concurrent first writes
+// create one key between them: the unique index on the current key of a user lets only one of
+// them insert, and the others use the key it inserted.
+func currentDataKey(ctx context.Context, userID uint) (models.DataKey, error) {
+	var key models.DataKey
+	if err := config.DB.WithContext(ctx).Where("user_id = ? AND current", userID).Limit(1).Find(&key).Error; err != nil {
+		return key, err
+	}
+	if key.ID != 0 {
+		return key, nil
+	}
+
+	db := config.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:     []clause.Column{{Name: "user_id"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "current"}}},
+		DoNothing:   true,
+	})
+	key, err := createDataKey(ctx, db, userID)
+	if err != nil || key.ID != 0 {
+		return key, err
+	}
+	err = config.DB.WithContext(ctx).Where("user_id = ? AND current", userID).Take(&key).Error
+	return key, err
+}
+
+// createDataKey generates a data key and inserts it with db as the current data key of the user.
+// The returned key has no ID if the insert was skipped by an ON CONFLICT clause of db.
+func createDataKey(ctx context.Context, db *gorm.DB, userID uint) (models.DataKey, error) {
+	plaintext := make([]byte, 32)
+	if _, err := rand.Read(plaintext); err != nil {
+		return models.DataKey{}, err
+	}
+
+	key := models.DataKey{UserID: userID, MasterKeyID: Keys.CurrentKeyID(), Current: true}
+	wrapped, err := Keys.Wrap(ctx, key.MasterKeyID, plaintext)
+	if err != nil {
+		return models.DataKey{}, err
+	}
+	key.WrappedKey = wrapped
+	if err := db.Create(&key).Error; err != nil || key.ID == 0 {
+		return models.DataKey{}, err
+	}
+
+	aead, err := newAEAD(plaintext)
+	if err != nil {
+		return models.DataKey{}, err
+	}
+	dataKeys.Store(key.ID, aead)
+	return key, nil
+}
+
+func dataKeyByID(ctx context.Context, id uint) (cipher.AEAD, error) {
+	if aead, ok := dataKeys.Load(id); ok {
+		return aead.(cipher.AEAD), nil
+	}
+
+	var key models.DataKey
+	if err := config.DB.WithContext(ctx).First(&key, id).Error; err != nil {
+		return nil, fmt.Errorf("data key %d: %w", id, err)
+	}
+	return dataKeyAEAD(ctx, key)
+}
+
+// dataKeyAEAD unwraps the data key, or takes it from the cache.
+func dataKeyAEAD(ctx context.Context, key models.DataKey) (cipher.AEAD, error) {
+	if aead, ok := dataKeys.Load(key.ID); ok {
+		return aead.(cipher.AEAD), nil
+	}
+
+	plaintext, err := Keys.Unwrap(ctx, key.MasterKeyID, key.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key %d with master key %q: %w", key.ID, key.MasterKeyID, err)
+	}
+	aead, err := newAEAD(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	dataKeys.Store(key.ID, aead)
+	return aead, nil
+}
@@ -0,0 +1,162 @@
+package encryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrUnknownMasterKey is returned when a data key was wrapped by a master key that is not configured.
+var ErrUnknownMasterKey = errors.New("unknown master key")
+
+// MasterKeys wraps and unwraps data keys, like a key management service would. Master keys
+// are identified by an ID stored next to every wrapped data key, so they can be rotated.
+type MasterKeys interface {
+	// CurrentKeyID is the ID of the master key new data keys are wrapped with.
+	CurrentKeyID() string
+	Wrap(ctx context.Context, keyID string, dataKey []byte) ([]byte, error)
+	Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// KeyRing holds the master keys in memory and wraps with AES-256-GCM. Previous master keys
+// only stay in the ring to unwrap the data keys that were not rewrapped yet.
+type KeyRing struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// NewKeyRing returns a ring of 32-byte master keys by ID, wrapping new data keys with current.
+func NewKeyRing(current string, keys map[string][]byte) (*KeyRing, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("current master key %q is missing", current)
+	}
+
+	ring := &KeyRing{current: current, keys: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("invalid master key ID %q", id)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("master key %q: %w", id, err)
+		}
+		ring.keys[id] = aead
+	}
+	return ring, nil
+}
+
+// LoadKeyFile reads a key ring from a JSON file standing in for a key management service:
+//
+//	{"current": "2026-10", "keys": {"2026-04": "<base64>", "2026-10": "<base64>"}}
+func LoadKeyFile(path string) (*KeyRing, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Current string            `json:"current"`
+		Keys    map[string]string `json:"keys"`
+	}
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("invalid key file: %w", err)
+	}
+
+	keys := make(map[string][]byte, len(file.Keys))
+	for id, encoded := range file.Keys {
+		if keys[id], err = base64.StdEncoding.DecodeString(encoded); err != nil {
+			return nil, fmt.Errorf("master key %q is not valid base64", id)
+		}
+	}
+	return NewKeyRing(file.Current, keys)
+}
+
+func (r *KeyRing) CurrentKeyID() string {
+	return r.current
+}
+
+func (r *KeyRing) Wrap(ctx context.Context, keyID string, dataKey []byte) ([]byte, error) {
+	aead, ok := r.keys[keyID]
+	if !ok {
+		return nil, ErrUnknownMasterKey
+	}
+	return seal(aead, dataKey, []byte(keyID))
+}
+
+func (r *KeyRing) Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := r.keys[keyID]
+	if !ok {
+		return nil, ErrUnknownMasterKey
+	}
+	return open(aead, wrapped, []byte(keyID))
+}
+
+// keysFromEnv configures the master keys: ENCRYPTION_KEYS=config reads the base64
+// ENCRYPTION_MASTER_KEY (ID ENCRYPTION_MASTER_KEY_ID) and the "id:base64" list
+// ENCRYPTION_PREVIOUS_MASTER_KEYS, ENCRYPTION_KEYS=file the key file ENCRYPTION_KEY_FILE.
+// Without ENCRYPTION_KEYS, encryption at rest is disabled.
+func keysFromEnv() (MasterKeys, error) {
+	switch source := os.Getenv("ENCRYPTION_KEYS"); source {
+	case "":
+		return nil, nil
+	case "config":
+		current := os.Getenv("ENCRYPTION_MASTER_KEY_ID")
+		if current == "" {
+			current = "default"
+		}
+		key, err := base64.StdEncoding.DecodeString(os.Getenv("ENCRYPTION_MASTER_KEY"))
+		if err != nil {
+			return nil, errors.New("ENCRYPTION_MASTER_KEY is not valid base64")
+		}
+
+		keys := map[string][]byte{current: key}
+		for _, entry := range strings.Split(os.Getenv("ENCRYPTION_PREVIOUS_MASTER_KEYS"), ",") {
+			if entry = strings.TrimSpace(entry); entry == "" {
+				continue
+			}
+			id, encoded, _ := strings.Cut(entry, ":")
+			if keys[id], err = base64.StdEncoding.DecodeString(encoded); err != nil {
+				return nil, fmt.Errorf("previous master key %q is not valid base64", id)
+			}
+		}
+		return NewKeyRing(current, keys)
+	case "file":
+		return LoadKeyFile(os.Getenv("ENCRYPTION_KEY_FILE"))
+	default:
+		return nil, fmt.Errorf("unknown encryption key source %q", source)
+	}
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, errors.New("key must be 32 bytes long")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts with a random nonce, which is prepended to the ciphertext.
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additionalData)
+}
@@ -0,0 +1,146 @@
+package encryption
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/models"
+)
+
+// RotateDataKeys gives every user a new current data key, wrapped by the current master key.
+// Values are only encrypted with it once rewritten, see ReencryptNotes.
+func RotateDataKeys(ctx context.Context) (int, error) {
+	var userIDs []uint
+	if err := config.DB.WithContext(ctx).Model(&models.User{}).Order("id").Pluck("id", &userIDs).Error; err != nil {
+		return 0, err
+	}
+
+	for i, userID := range userIDs {
+		if _, err := NewDataKey(ctx, userID); err != nil {
+			return i, err
+		}
+	}
+	return len(userIDs), nil
+}
+
+// encryptedColumns lists the columns encrypted at rest, by table. Each table has a user_id column
+// naming the owner of the data key.
+var encryptedColumns = []struct {
+	table   string
+	columns []string
+}{
+	{"notes", []string{"title", "content"}},
+	{"tasks", []string{"text"}},
+	{"note_links", []string{"target_title"}},
+	{"notifications", []string{"message"}},
+}
+
+// ReencryptNotes rewrites the title and content of every note, batchSize notes at a time, so
+// they are encrypted with the current data key of their owner. Plaintext notes get encrypted.
+func ReencryptNotes(ctx context.Context, batchSize int) (int, error) {
+	return reencrypt[models.Note](ctx, batchSize, "title", "content")
+}
+
+// ReencryptTasks rewrites the text of every task, see ReencryptNotes.
+func ReencryptTasks(ctx context.Context, batchSize int) (int, error) {
+	return reencrypt[models.Task](ctx, batchSize, "text")
+}
+
+// ReencryptNoteLinks rewrites the target title of every note link, see ReencryptNotes.
+func ReencryptNoteLinks(ctx context.Context, batchSize int) (int, error) {
+	return reencrypt[models.NoteLink](ctx, batchSize, "target_title")
+}
+
+// ReencryptNotifications rewrites the message of every notification, see ReencryptNotes.
+func ReencryptNotifications(ctx context.Context, batchSize int) (int, error) {
+	return reencrypt[models.Notification](ctx, batchSize, "message")
+}
+
+// reencrypt rewrites the columns of every row of the model, batchSize rows at a time. Each batch
+// is read again and locked in its own transaction, so edits made while the command runs are
+// neither overwritten with older values nor left with an older data key.
+func reencrypt[T any](ctx context.Context, batchSize int, columns ...string) (int, error) {
+	count := 0
+	db := config.DB.WithContext(ctx)
+
+	for lastID := 0; ; {
+		var ids []int
+		if err := db.Model(new(T)).Where("id > ?", lastID).Order("id").Limit(batchSize).Pluck("id", &ids).Error; err != nil {
+			return count, err
+		}
+		if len(ids) == 0 {
+			return count, nil
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var rows []T
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", ids).Order("id").Find(&rows).Error; err != nil {
+				return err
+			}
+			for i := range rows {
+				// UpdateColumns keeps updated_at: re-encrypting does not change the row
+				if err := tx.Model(&rows[i]).Select(columns).UpdateColumns(&rows[i]).Error; err != nil {
+					return err
+				}
+			}
+			count += len(rows)
+			return nil
+		})
+		if err != nil {
+			return count, err
+		}
+		lastID = ids[len(ids)-1]
+	}
+}
+
+// PruneDataKeys deletes the data keys that are neither the current key of their user nor
+// used by any encrypted column anymore.
+func PruneDataKeys(ctx context.Context) (int64, error) {
+	db := config.DB.WithContext(ctx)
+	query := db.Where("id NOT IN (?)", db.Model(&models.DataKey{}).Select("MAX(id)").Group("user_id"))
+	for _, table := range encryptedColumns {
+		var conditions []string
+		var args []interface{}
+		for _, column := range table.columns {
+			for _, p := range []string{prefix, legacyPrefix} {
+				conditions = append(conditions, fmt.Sprintf("%s.%s LIKE ? || data_keys.id || ':%%'", table.table, column))
+				args = append(args, p)
+			}
+		}
+		query = query.Where(fmt.Sprintf("NOT EXISTS (SELECT 1 FROM %s WHERE %s.user_id = data_keys.user_id AND (%s))",
+			table.table, table.table, strings.Join(conditions, " OR ")), args...)
+	}
+
+	result := query.Delete(&models.DataKey{})
+	return result.RowsAffected, result.Error
+}
+
+// RewrapDataKeys wraps the data keys still wrapped by a previous master key with the current
+// one, after which the previous master keys can be removed from the configuration.
+func RewrapDataKeys(ctx context.Context) (int, error) {
+	var keys []models.DataKey
+	if err := config.DB.WithContext(ctx).Where("master_key_id <> ?", Keys.CurrentKeyID()).Find(&keys).Error; err != nil {
+		return 0, err
+	}
+
+	for i, key := range keys {
+		plaintext, err := Keys.Unwrap(ctx, key.MasterKeyID, key.WrappedKey)
+		if err != nil {
+			return i, err
+		}
+		wrapped, err := Keys.Wrap(ctx, Keys.CurrentKeyID(), plaintext)
+		if err != nil {
+			return i, err
+		}
+		if err := config.DB.WithContext(ctx).Model(&key).
+			Updates(map[string]interface{}{"master_key_id": Keys.CurrentKeyID(), "wrapped_key": wrapped}).Error; err != nil {
+			return i, err
+		}
+	}
+	return len(keys), nil
+}
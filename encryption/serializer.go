@@ -0,0 +1,74 @@
+package encryption
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm/schema"
+)
+
+func init() {
+	schema.RegisterSerializer("encrypted", Serializer{})
+}
+
+// Serializer encrypts string fields tagged `gorm:"serializer:encrypted"` on write and decrypts
+// them on read, so handlers only ever see plaintext. The row's UserID field selects the data
+// key; reading needs no other column, since encrypted values name their data key.
+//
+// Values are bound to their "<table>.<column>". Structs scanning an encrypted column under
+// another name or table name the column in the tag, as in
+// `gorm:"serializer:encrypted;encrypted:notes.title"`.
+//
+// Updates with a column name or a map bypass serializers in GORM: they must pass the struct,
+// as in Model(&note).Select("content").Updates(&note).
+type Serializer struct{}
+
+func (Serializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var value string
+	switch v := dbValue.(type) {
+	case nil:
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	default:
+		return fmt.Errorf("unsupported value %T for encrypted field %s", dbValue, field.Name)
+	}
+
+	plaintext, err := Decrypt(ctx, column(field), value)
+	if err != nil {
+		return fmt.Errorf("field %s: %w", field.Name, err)
+	}
+	field.ReflectValueOf(ctx, dst).SetString(plaintext)
+	return nil
+}
+
+func (Serializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	plaintext, ok := fieldValue.(string)
+	if !ok {
+		return nil, fmt.Errorf("encrypted field %s must be a string", field.Name)
+	}
+	if !Enabled() {
+		return plaintext, nil
+	}
+
+	userField := field.Schema.LookUpField("UserID")
+	if userField == nil {
+		return nil, errors.New("encrypted fields need a UserID field in their model")
+	}
+	userID, zero := userField.ValueOf(ctx, dst)
+	if zero {
+		return nil, fmt.Errorf("cannot encrypt field %s without the UserID of the row", field.Name)
+	}
+	return Encrypt(ctx, userID.(uint), column(field), plaintext)
+}
+
+// column returns the "<table>.<column>" the value of the field is bound to.
+func column(field *schema.Field) string {
+	if name := field.TagSettings["ENCRYPTED"]; name != "" {
+		return name
+	}
+	return field.Schema.Table + "." + field.DBName
+}
@@ -1,19 +1,29 @@
 package handlers
 
 import (
+	"bytes"
+	"encoding/base64"
+	"math"
 	"net/http"
+	"regexp"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jung-kurt/gofpdf"
 )
 
+// inlineImagePattern matches the Markdown images of a note that refer to an attachment: ![alt](attachment:12).
+var inlineImagePattern = regexp.MustCompile(`!\[([^\]]*)\]\(attachment:(\d+)\)`)
+
 // ExportNotebook exports a notebook as a PDF
 func ExportNotebook(c *gin.Context) {
 	var request struct {
 		NotebookName string `json:"notebook_name"`
 		Notes        []struct {
-			Title   string `json:"title"`
-			Content string `json:"content"`
+			Title    string            `json:"title"`
+			Content  string            `json:"content"`
+			Notebook string            `json:"notebook"` // Path of the (sub-)notebook the note belongs to
+			Images   map[string]string `json:"images"`   // Base64 JPEG of the inline images, by attachment ID
 		} `json:"notes"`
 	}
 
@@ -31,11 +41,21 @@ func ExportNotebook(c *gin.Context) {
 
 	// Add notes to the PDF
 	pdf.SetFont("Arial", "", 12)
+	currentNotebook := request.NotebookName
 	for _, note := range request.Notes {
+		// Start a new section whenever the notes of a sub-notebook begin
+		if note.Notebook != "" && note.Notebook != currentNotebook {
+			currentNotebook = note.Notebook
+			pdf.Ln(15)
+			pdf.SetFont("Arial", "B", 14)
+			pdf.Cell(40, 10, "Notebook: "+currentNotebook)
+			pdf.SetFont("Arial", "", 12)
+		}
+
 		pdf.Ln(10)
 		pdf.Cell(40, 10, "Note: "+note.Title)
 		pdf.Ln(5)
-		pdf.MultiCell(0, 10, note.Content, "", "", false)
+		writeContent(pdf, note.Content, note.Images)
 	}
 
 	// Output the PDF
@@ -50,8 +70,9 @@ func ExportNotebook(c *gin.Context) {
 // ExportNote exports a single note as a PDF
 func ExportNote(c *gin.Context) {
 	var request struct {
-		Title   string `json:"title"`
-		Content string `json:"content"`
+		Title   string            `json:"title"`
+		Content string            `json:"content"`
+		Images  map[string]string `json:"images"` // Base64 JPEG of the inline images, by attachment ID
 	}
 
 	// Bind the JSON payload
@@ -69,7 +90,7 @@ func ExportNote(c *gin.Context) {
 	// Add note content to the PDF
 	pdf.SetFont("Arial", "", 12)
 	pdf.Ln(10)
-	pdf.MultiCell(0, 10, request.Content, "", "", false)
+	writeContent(pdf, request.Content, request.Images)
 
 	// Output the PDF
 	c.Header("Content-Type", "application/pdf")
@@ -79,3 +100,43 @@ func ExportNote(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate PDF"})
 	}
 }
+
+// writeContent writes the content of a note, embedding its inline images where they are
+// referenced. Images that were not sent are written as their alt text.
+func writeContent(pdf *gofpdf.Fpdf, content string, images map[string]string) {
+	last := 0
+	for _, match := range inlineImagePattern.FindAllStringSubmatchIndex(content, -1) {
+		writeText(pdf, content[last:match[0]])
+		last = match[1]
+
+		alt, id := content[match[2]:match[3]], content[match[4]:match[5]]
+		data, err := base64.StdEncoding.DecodeString(images[id])
+		if err != nil || len(data) == 0 {
+			writeText(pdf, "["+alt+"]")
+			continue
+		}
+
+		name := "attachment-" + id
+		info := pdf.RegisterImageOptionsReader(name, gofpdf.ImageOptions{ImageType: "JPEG"}, bytes.NewReader(data))
+		if pdf.Err() {
+			// A broken image must not fail the whole export
+			pdf.ClearError()
+			writeText(pdf, "["+alt+"]")
+			continue
+		}
+
+		// Images are placed at 96 dpi, shrunk to the width of the page if needed
+		left, _, right, _ := pdf.GetMargins()
+		pageWidth, _ := pdf.GetPageSize()
+		width := math.Min(info.Width()*72/96, pageWidth-left-right)
+		pdf.ImageOptions(name, left, -1, width, 0, true, gofpdf.ImageOptions{ImageType: "JPEG"}, 0, "")
+	}
+	writeText(pdf, content[last:])
+}
+
+func writeText(pdf *gofpdf.Fpdf, text string) {
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	pdf.MultiCell(0, 10, strings.Trim(text, "\n"), "", "", false)
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/models"
+)
+
+// GetNotebookActivity retrieves the activity log of a notebook
+func GetNotebookActivity(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	notebookID := c.Param("id")
+
+	var notebook models.Notebook
+	if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", notebookID).First(&notebook).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notebook not found or access denied"})
+		return
+	}
+
+	// Workspace admins see where every change came from, other members only their own
+	listActivities(c, config.DB.Where("notebook_id = ?", notebook.ID), isWorkspaceAdmin(notebook.WorkspaceID, userID))
+}
+
+// GetMyActivity retrieves the activity performed by the user
+func GetMyActivity(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	listActivities(c, config.DB.Where("actor_id = ?", userID), false)
+}
+
+// Private helper functions.
+
+// listActivities applies the action/date filters and pagination from the query string and writes the response.
+// The IP and user agent of activities performed by others are only shown when showRequests is set.
+func listActivities(c *gin.Context, query *gorm.DB, showRequests bool) {
+	query = query.Model(&models.Activity{})
+
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if from := c.Query("from"); from != "" {
+		fromTime, err := parseDateParam(from)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date"})
+			return
+		}
+		query = query.Where("created_at >= ?", fromTime)
+	}
+	if to := c.Query("to"); to != "" {
+		toTime, err := parseDateParam(to)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date"})
+			return
+		}
+		// A plain date includes the whole day.
+		if len(to) == len("2006-01-02") {
+			toTime = toTime.AddDate(0, 0, 1)
+		}
+		query = query.Where("created_at < ?", toTime)
+	}
+
+	pageInt, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || pageInt < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page"})
+		return
+	}
+	limitInt, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limitInt < 1 || limitInt > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count activity"})
+		return
+	}
+
+	var activities []models.Activity
+	if err := query.Order("created_at DESC, id DESC").Limit(limitInt).Offset((pageInt - 1) * limitInt).Find(&activities).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch activity"})
+		return
+	}
+	redactActivities(activities, c.GetString("user_id"), showRequests)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       activities,
+		"total":      total,
+		"page":       pageInt,
+		"limit":      limitInt,
+		"totalPages": int(math.Ceil(float64(total) / float64(limitInt))),
+	})
+}
+
+// redactActivities hides what a viewer may not see of stored activities, which stay untouched. Note
+// snapshots lose the title and content older versions copied there, and activities of other actors
+// lose their IP and user agent unless showRequests is set.
+func redactActivities(activities []models.Activity, viewerID string, showRequests bool) {
+	for i := range activities {
+		activity := &activities[i]
+		if activity.TargetType == "note" {
+			activity.Before = redactNoteSnapshot(activity.Before)
+			activity.After = redactNoteSnapshot(activity.After)
+		}
+		if !showRequests && (activity.ActorID == nil || strconv.FormatUint(uint64(*activity.ActorID), 10) != viewerID) {
+			activity.IP = ""
+			activity.UserAgent = ""
+		}
+	}
+}
+
+// redactNoteSnapshot removes the title and content from a JSON snapshot of a note.
+func redactNoteSnapshot(snapshot string) string {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(snapshot), &fields); err != nil {
+		return snapshot
+	}
+	if _, ok := fields["title"]; !ok {
+		if _, ok := fields["content"]; !ok {
+			return snapshot
+		}
+	}
+	delete(fields, "title")
+	delete(fields, "content")
+	return activitySummary(fields)
+}
+
+// parseDateParam accepts either a date (2006-01-02) or an RFC 3339 timestamp.
+func parseDateParam(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// recordActivity appends an entry to the activity log. The actor defaults to the
+// authenticated user and the request's IP and user agent are always filled in.
+// Failures are logged but never fail the request.
+func recordActivity(c *gin.Context, activity models.Activity, before, after interface{}) {
+	fillActivity(c, &activity, before, after)
+	if err := config.DB.Create(&activity).Error; err != nil {
+		log.Printf("Failed to record activity %s: %v", activity.Action, err)
+	}
+}
+
+// fillActivity fills in the actor, request details and snapshots of an activity.
+func fillActivity(c *gin.Context, activity *models.Activity, before, after interface{}) {
+	if activity.ActorID == nil {
+		if userID, exists := c.Get("user_id"); exists {
+			if userIDUint, err := strconv.ParseUint(userID.(string), 10, 32); err == nil {
+				actorID := uint(userIDUint)
+				activity.ActorID = &actorID
+			}
+		}
+	}
+
+	activity.Before = activitySummary(before)
+	activity.After = activitySummary(after)
+	activity.IP = c.ClientIP()
+	activity.UserAgent = c.Request.UserAgent()
+}
+
+func activitySummary(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	summary, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(summary)
+}
+
+// noteSummary is the before/after snapshot of a note. It never holds the title or content, so no
+// text of a note outlives it in the activity log or escapes its encryption.
+func noteSummary(note models.Note) gin.H {
+	return gin.H{
+		"notebook_id": note.NotebookID,
+		"locked":      note.Locked,
+	}
+}
+
+// noteUpdateSummary is the after snapshot of an updated note, naming the fields the update changed.
+func noteUpdateSummary(before, after models.Note) gin.H {
+	changed := []string{}
+	if before.Title != after.Title {
+		changed = append(changed, "title")
+	}
+	if before.Content != after.Content {
+		changed = append(changed, "content")
+	}
+	if before.NotebookID != after.NotebookID {
+		changed = append(changed, "notebook_id")
+	}
+	if before.Locked != after.Locked {
+		changed = append(changed, "locked")
+	}
+
+	summary := noteSummary(after)
+	summary["changed"] = changed
+	return summary
+}
+
+func notebookSummary(notebook models.Notebook) gin.H {
+	return gin.H{"name": notebook.Name}
+}
+
+func uintPtr(value uint) *uint {
+	return &value
+}
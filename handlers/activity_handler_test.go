@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"noteapp-framework-backend/models"
+)
+
+func TestRedactActivities(t *testing.T) {
+	activities := []models.Activity{
+		{ActorID: uintPtr(1), TargetType: "note", Before: `{"content":"my secret","locked":false,"title":"Passwords"}`, IP: "10.0.0.1", UserAgent: "curl"},
+		{ActorID: uintPtr(2), TargetType: "notebook", Before: `{"name":"Work"}`, IP: "10.0.0.2", UserAgent: "firefox"},
+		{TargetType: "user", IP: "10.0.0.3", UserAgent: "chrome"},
+	}
+	redactActivities(activities, "1", false)
+
+	// Stored note text is never shown, other snapshots are left as they are
+	assert.Equal(t, `{"locked":false}`, activities[0].Before)
+	assert.Equal(t, `{"name":"Work"}`, activities[1].Before)
+
+	// Only the viewer's own requests keep their IP and user agent
+	assert.Equal(t, "10.0.0.1", activities[0].IP)
+	assert.Equal(t, "curl", activities[0].UserAgent)
+	assert.Empty(t, activities[1].IP)
+	assert.Empty(t, activities[1].UserAgent)
+	assert.Empty(t, activities[2].IP)
+
+	activities = []models.Activity{{ActorID: uintPtr(2), IP: "10.0.0.2", UserAgent: "firefox"}}
+	redactActivities(activities, "1", true)
+	assert.Equal(t, "10.0.0.2", activities[0].IP)
+}
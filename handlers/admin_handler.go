@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/models"
+)
+
+// adminUser is a user as listed by the admin API, without the password hash.
+type adminUser struct {
+	ID        uint      `json:"id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	Disabled  bool      `json:"disabled"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GetUsers lists the users by username, page by page. ?q= searches usernames containing it.
+func GetUsers(c *gin.Context) {
+	pageInt, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || pageInt < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page"})
+		return
+	}
+	limitInt, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limitInt < 1 || limitInt > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+
+	query := config.DB.Model(&models.User{})
+	if search := c.Query("q"); search != "" {
+		escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(search)
+		query = query.Where("username ILIKE ?", "%"+escaped+"%")
+	}
+	switch c.Query("role") {
+	case "":
+	case models.UserRoleUser, models.UserRoleAdmin:
+		query = query.Where("role = ?", c.Query("role"))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be user or admin"})
+		return
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count users"})
+		return
+	}
+
+	var users []adminUser
+	if err := query.Select("id", "username", "role", "disabled", "created_at").
+		Order("username").Limit(limitInt).Offset((pageInt - 1) * limitInt).
+		Scan(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       users,
+		"total":      total,
+		"page":       pageInt,
+		"limit":      limitInt,
+		"totalPages": int(math.Ceil(float64(total) / float64(limitInt))),
+	})
+}
+
+// GetUserUsage reports the usage of a user against their quotas, like GET /me/usage does for
+// the user, along with the number of workspaces they are a member of.
+func GetUserUsage(c *gin.Context) {
+	user, ok := adminTargetUser(c)
+	if !ok {
+		return
+	}
+
+	usage, err := userUsage(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute usage"})
+		return
+	}
+	var workspaces int64
+	if err := config.DB.Model(&models.WorkspaceMember{}).Where("user_id = ?", user.ID).Count(&workspaces).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute usage"})
+		return
+	}
+	usage["workspaces"] = workspaces
+
+	c.JSON(http.StatusOK, gin.H{"data": usage, "user": adminUserOf(user)})
+}
+
+// DisableUser disables an account: the user can no longer log in and every session is logged out
+func DisableUser(c *gin.Context) {
+	setUserDisabled(c, true)
+}
+
+// EnableUser enables a disabled account again
+func EnableUser(c *gin.Context) {
+	setUserDisabled(c, false)
+}
+
+// LogoutUser logs out every session of a user. Access and refresh tokens issued until now stop working.
+func LogoutUser(c *gin.Context) {
+	user, ok := adminTargetUser(c)
+	if !ok {
+		return
+	}
+
+	if err := config.DB.Model(&user).Update("sessions_revoked_at", time.Now().UTC()).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out user"})
+		return
+	}
+
+	recordActivity(c, models.Activity{
+		Action:     models.ActivityUserLogoutAll,
+		TargetType: "user",
+		TargetID:   uintPtr(user.ID),
+	}, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "User logged out of every session"})
+}
+
+// ResetUserPassword sets a new password for a user and logs out every session
+func ResetUserPassword(c *gin.Context) {
+	var input struct {
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := adminTargetUser(c)
+	if !ok {
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	if err := config.DB.Model(&user).Updates(map[string]interface{}{
+		"password":            string(hashedPassword),
+		"sessions_revoked_at": time.Now().UTC(),
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	recordActivity(c, models.Activity{
+		Action:     models.ActivityUserResetPass,
+		TargetType: "user",
+		TargetID:   uintPtr(user.ID),
+	}, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
+
+// UpdateUserRole makes a user an admin or a regular user. Admins cannot change their own role,
+// so there is always an admin left.
+func UpdateUserRole(c *gin.Context) {
+	var input struct {
+		Role string `json:"role" binding:"required,oneof=user admin"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := adminTargetUser(c)
+	if !ok {
+		return
+	}
+	if c.GetString("user_id") == strconv.FormatUint(uint64(user.ID), 10) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Admins cannot change their own role"})
+		return
+	}
+
+	before := gin.H{"role": user.Role}
+	if err := config.DB.Model(&user).Update("role", input.Role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+	user.Role = input.Role
+
+	recordActivity(c, models.Activity{
+		Action:     models.ActivityUserRoleChange,
+		TargetType: "user",
+		TargetID:   uintPtr(user.ID),
+	}, before, gin.H{"role": user.Role})
+
+	c.JSON(http.StatusOK, gin.H{"data": adminUserOf(user)})
+}
+
+// GetStats returns global counts of users, workspaces, notebooks, notes and attachments, across all workspaces
+func GetStats(c *gin.Context) {
+	var stats struct {
+		Users           int64 `json:"users"`
+		DisabledUsers   int64 `json:"disabled_users"`
+		Admins          int64 `json:"admins"`
+		Workspaces      int64 `json:"workspaces"`
+		Notebooks       int64 `json:"notebooks"`
+		Notes           int64 `json:"notes"`
+		ArchivedNotes   int64 `json:"archived_notes"`
+		Attachments     int64 `json:"attachments"`
+		AttachmentBytes int64 `json:"attachment_bytes"`
+	}
+
+	counts := []struct {
+		query *gorm.DB
+		count *int64
+	}{
+		{config.DB.Model(&models.User{}), &stats.Users},
+		{config.DB.Model(&models.User{}).Where("disabled = ?", true), &stats.DisabledUsers},
+		{config.DB.Model(&models.User{}).Where("role = ?", models.UserRoleAdmin), &stats.Admins},
+		{config.DB.Model(&models.Workspace{}), &stats.Workspaces},
+		{config.DB.Model(&models.Notebook{}), &stats.Notebooks},
+		{config.DB.Model(&models.Note{}), &stats.Notes},
+		{config.DB.Model(&models.Note{}).Where("archived = ?", true), &stats.ArchivedNotes},
+		{config.DB.Model(&models.Attachment{}), &stats.Attachments},
+	}
+	for _, count := range counts {
+		if err := count.query.Count(count.count).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute statistics"})
+			return
+		}
+	}
+	if err := config.DB.Model(&models.Attachment{}).Select("COALESCE(SUM(size), 0)").Scan(&stats.AttachmentBytes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute statistics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": stats})
+}
+
+// Private helper functions.
+
+// adminTargetUser loads the user of the :id parameter.
+func adminTargetUser(c *gin.Context) (models.User, bool) {
+	var user models.User
+	if err := config.DB.First(&user, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return user, false
+	}
+	return user, true
+}
+
+func adminUserOf(user models.User) adminUser {
+	return adminUser{ID: user.ID, Username: user.Username, Role: user.Role, Disabled: user.Disabled, CreatedAt: user.CreatedAt}
+}
+
+// setUserDisabled disables or enables the account of the :id parameter. Disabling also logs
+// out every session, and admins cannot disable themselves.
+func setUserDisabled(c *gin.Context, disabled bool) {
+	user, ok := adminTargetUser(c)
+	if !ok {
+		return
+	}
+	if disabled && c.GetString("user_id") == strconv.FormatUint(uint64(user.ID), 10) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Admins cannot disable themselves"})
+		return
+	}
+
+	updates := map[string]interface{}{"disabled": disabled}
+	action := models.ActivityUserEnable
+	if disabled {
+		// Calendar subscriptions end with the sessions; the user creates a new URL once enabled again
+		updates["sessions_revoked_at"] = time.Now().UTC()
+		updates["calendar_token_hash"] = nil
+		action = models.ActivityUserDisable
+	}
+	if err := config.DB.Model(&user).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+	user.Disabled = disabled
+
+	recordActivity(c, models.Activity{
+		Action:     action,
+		TargetType: "user",
+		TargetID:   uintPtr(user.ID),
+	}, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{"data": adminUserOf(user)})
+}
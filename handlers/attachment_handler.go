@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/images"
+	"noteapp-framework-backend/models"
+	"noteapp-framework-backend/storage"
+)
+
+// multipartOverhead is the room left in the request body for the multipart headers around the file.
+const multipartOverhead = 1 << 20
+
+var errInvalidRange = errors.New("invalid range")
+
+// UploadAttachment attaches the file of the multipart "file" field to a note. The content
+// type is sniffed from the content; identical content is stored only once.
+func UploadAttachment(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var note models.Note
+	if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", c.Param("id")).First(&note).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found or access denied"})
+		return
+	}
+
+	maxSize := config.GetAttachmentMaxSize()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+multipartOverhead)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("File exceeds the limit of %d bytes", maxSize)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is required"})
+		return
+	}
+	if header.Size > maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("File exceeds the limit of %d bytes", maxSize)})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+
+	// The type announced by the client is ignored: only the content decides
+	sniff := make([]byte, 512)
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	n, _ := io.ReadFull(file, sniff)
+	contentType := http.DetectContentType(sniff[:n])
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+
+	attachment := models.Attachment{
+		UserID:      note.UserID,
+		NoteID:      uint(note.ID),
+		Filename:    attachmentFilename(header.Filename),
+		ContentType: contentType,
+		Size:        header.Size,
+		SHA256:      sum,
+	}
+	// The content is stored and referenced under the lock of its hash, so pruning cannot delete
+	// it in between
+	status, failure := http.StatusInternalServerError, "Failed to save attachment"
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if !enforceQuota(c, tx, userID, quotaAttachmentBytes, header.Size) {
+			return errQuotaExceeded
+		}
+		if images.IsImage(contentType) {
+			// Images are stored without their metadata, so their hash and size change
+			if err := storeImageAttachment(c, tx, file, &attachment); err != nil {
+				status, failure = http.StatusUnprocessableEntity, "Failed to process image"
+				if errors.Is(err, images.ErrTooLarge) {
+					status, failure = http.StatusRequestEntityTooLarge, "Image has too many pixels"
+				}
+				return err
+			}
+		} else {
+			if err := lockAttachmentHash(tx, sum); err != nil {
+				return err
+			}
+			if err := storeAttachmentBlob(c, sum, file, header.Size, contentType); err != nil {
+				failure = "Failed to store file"
+				return err
+			}
+		}
+		return tx.Create(&attachment).Error
+	})
+	if errors.Is(err, errQuotaExceeded) {
+		return
+	}
+	if err != nil {
+		c.JSON(status, gin.H{"error": failure})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": attachment})
+}
+
+// GetNoteAttachments retrieves the attachments of a note
+func GetNoteAttachments(c *gin.Context) {
+	_, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var note models.Note
+	if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", noteIDParam(c)).First(&note).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found or access denied"})
+		return
+	}
+
+	var attachments []models.Attachment
+	if err := config.DB.Where("note_id = ?", note.ID).Order("id").Find(&attachments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attachments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": attachments})
+}
+
+// DownloadAttachment streams an attachment. A single "Range: bytes=" range is answered with
+// 206 Partial Content so large files can be resumed or played from the middle.
+func DownloadAttachment(c *gin.Context) {
+	_, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var attachment models.Attachment
+	if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", c.Param("id")).First(&attachment).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found or access denied"})
+		return
+	}
+
+	serveAttachment(c, attachment, "attachment")
+}
+
+// DeleteAttachment deletes an attachment, and its content once no other attachment shares it
+func DeleteAttachment(c *gin.Context) {
+	_, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var attachment models.Attachment
+	if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", c.Param("id")).First(&attachment).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found or access denied"})
+		return
+	}
+
+	if err := config.DB.Delete(&attachment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete attachment"})
+		return
+	}
+	pruneAttachmentBlobs([]string{attachment.SHA256})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Attachment deleted successfully"})
+}
+
+// Private helper functions.
+
+// attachmentKey is the storage key of content with the given SHA-256.
+func attachmentKey(sum string) string {
+	return "sha256/" + sum[:2] + "/" + sum
+}
+
+// storeAttachmentBlob uploads the content unless content with the same hash is already stored.
+func storeAttachmentBlob(c *gin.Context, sum string, r io.Reader, size int64, contentType string) error {
+	key := attachmentKey(sum)
+
+	stored, err := storage.Files.Exists(c.Request.Context(), key)
+	if err != nil || stored {
+		return err
+	}
+	return storage.Files.Put(c.Request.Context(), key, r, size, contentType)
+}
+
+// serveAttachment writes the content of the attachment, honouring a single byte range.
+// disposition is "attachment" or "inline".
+func serveAttachment(c *gin.Context, attachment models.Attachment, disposition string) {
+	offset, length, partial, err := parseByteRange(c.GetHeader("Range"), attachment.Size)
+	if err != nil {
+		c.Header("Content-Range", fmt.Sprintf("bytes */%d", attachment.Size))
+		c.JSON(http.StatusRequestedRangeNotSatisfiable, gin.H{"error": "Range not satisfiable"})
+		return
+	}
+
+	content, err := storage.Files.Get(c.Request.Context(), attachmentKey(attachment.SHA256), offset, length)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read attachment"})
+		return
+	}
+	defer content.Close()
+
+	headers := map[string]string{
+		"Accept-Ranges":          "bytes",
+		"Content-Disposition":    mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename}),
+		"ETag":                   `"` + attachment.SHA256 + `"`,
+		"X-Content-Type-Options": "nosniff",
+	}
+	status := http.StatusOK
+	if partial {
+		status = http.StatusPartialContent
+		headers["Content-Range"] = fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, attachment.Size)
+	}
+
+	c.DataFromReader(status, length, attachment.ContentType, content, headers)
+}
+
+// parseByteRange parses a "bytes=" Range header against content of the given size. Without a
+// header, or with several ranges, the whole content is returned and partial is false.
+func parseByteRange(header string, size int64) (offset, length int64, partial bool, err error) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if header == "" || strings.Contains(spec, ",") {
+		return 0, size, false, nil
+	}
+	if !ok {
+		return 0, 0, false, errInvalidRange
+	}
+
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, 0, false, errInvalidRange
+	}
+
+	if first == "" {
+		// bytes=-500 is the last 500 bytes
+		suffix, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || suffix <= 0 || size == 0 {
+			return 0, 0, false, errInvalidRange
+		}
+		suffix = min(suffix, size)
+		return size - suffix, suffix, true, nil
+	}
+
+	offset, err = strconv.ParseInt(first, 10, 64)
+	if err != nil || offset < 0 || offset >= size {
+		return 0, 0, false, errInvalidRange
+	}
+	end := size - 1
+	if last != "" {
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < offset {
+			return 0, 0, false, errInvalidRange
+		}
+		end = min(end, size-1)
+	}
+
+	return offset, end - offset + 1, true, nil
+}
+
+// attachmentFilename keeps the base name of an uploaded file, at most 255 bytes long.
+func attachmentFilename(name string) string {
+	name = name[strings.LastIndexAny(name, `/\`)+1:]
+	if name == "" {
+		name = "file"
+	}
+	for len(name) > 255 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
+
+// attachmentHashes returns the content hashes of the attachments of the notes, which are
+// passed as IDs or as a subquery. They are collected before notes are deleted, to prune after.
+func attachmentHashes(db *gorm.DB, noteIDs interface{}) []string {
+	var hashes []string
+	if err := db.Model(&models.Attachment{}).Distinct("sha256").Where("note_id IN (?)", noteIDs).Pluck("sha256", &hashes).Error; err != nil {
+		log.Printf("Failed to fetch attachment hashes: %v", err)
+	}
+	return hashes
+}
+
+// pruneAttachmentBlobs deletes the stored content, and image variants, of the hashes no
+// attachment refers to anymore.
+func pruneAttachmentBlobs(hashes []string) {
+	for _, sum := range hashes {
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			if err := lockAttachmentHash(tx, sum); err != nil {
+				return err
+			}
+			var count int64
+			if err := tx.Model(&models.Attachment{}).Where("sha256 = ?", sum).Count(&count).Error; err != nil || count > 0 {
+				return err
+			}
+
+			keys := []string{attachmentKey(sum)}
+			for _, variant := range images.Variants {
+				keys = append(keys, imageVariantKey(sum, variant.File()))
+			}
+			for _, key := range keys {
+				if err := storage.Files.Delete(context.Background(), key); err != nil {
+					log.Printf("Failed to delete attachment content %s: %v", key, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("Failed to prune attachment content %s: %v", sum, err)
+		}
+	}
+}
+
+// lockAttachmentHash takes the lock of a content hash until the transaction ends. Uploads hold it
+// while checking for and referencing stored content, pruning while deleting unreferenced content.
+func lockAttachmentHash(tx *gorm.DB, sum string) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", sum).Error
+}
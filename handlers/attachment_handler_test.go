@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseByteRange(t *testing.T) {
+	for _, tc := range []struct {
+		header          string
+		offset, length  int64
+		partial, failed bool
+	}{
+		{header: "", offset: 0, length: 100},
+		{header: "bytes=0-9", offset: 0, length: 10, partial: true},
+		{header: "bytes=90-", offset: 90, length: 10, partial: true},
+		{header: "bytes=90-500", offset: 90, length: 10, partial: true},
+		{header: "bytes=-20", offset: 80, length: 20, partial: true},
+		{header: "bytes=-500", offset: 0, length: 100, partial: true},
+		{header: "bytes=0-1,5-6", offset: 0, length: 100},
+		{header: "bytes=100-", failed: true},
+		{header: "bytes=9-5", failed: true},
+		{header: "items=0-5", failed: true},
+		{header: "bytes=abc", failed: true},
+	} {
+		offset, length, partial, err := parseByteRange(tc.header, 100)
+		if tc.failed {
+			assert.Error(t, err, tc.header)
+			continue
+		}
+		assert.NoError(t, err, tc.header)
+		assert.Equal(t, tc.offset, offset, tc.header)
+		assert.Equal(t, tc.length, length, tc.header)
+		assert.Equal(t, tc.partial, partial, tc.header)
+	}
+}
+
+func TestAttachmentFilename(t *testing.T) {
+	assert.Equal(t, "report.pdf", attachmentFilename(`C:\Users\bob\report.pdf`))
+	assert.Equal(t, "file", attachmentFilename("uploads/"))
+	assert.Len(t, attachmentFilename(strings.Repeat("é", 200)), 254)
+}
@@ -1,15 +1,23 @@
 package handlers
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"log"
+	"math"
 	"net/http"
 	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/loginguard"
+	"noteapp-framework-backend/middleware"
 	"noteapp-framework-backend/models"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 func Register(c *gin.Context) {
@@ -37,8 +45,14 @@ func Register(c *gin.Context) {
 	}
 	user.Password = string(hashedPassword)
 
-	// Create user
-	if err := config.DB.Create(&user).Error; err != nil {
+	// Create user, with their personal workspace
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return createPersonalWorkspace(tx, user.ID)
+	})
+	if err != nil {
 		// Check for unique constraint violation
 		if isUniqueConstraintError(err) {
 			c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
@@ -48,6 +62,13 @@ func Register(c *gin.Context) {
 		return
 	}
 
+	recordActivity(c, models.Activity{
+		ActorID:    uintPtr(user.ID),
+		Action:     models.ActivityUserRegister,
+		TargetType: "user",
+		TargetID:   uintPtr(user.ID),
+	}, nil, gin.H{"username": user.Username})
+
 	c.JSON(http.StatusCreated, gin.H{
 		"id":       user.ID,
 		"username": user.Username,
@@ -57,7 +78,8 @@ func Register(c *gin.Context) {
 
 func Login(c *gin.Context) {
 	var loginData struct {
-		Username string `json:"username" binding:"required"`
+		// Usernames are at most 255 characters long, like the users.username column
+		Username string `json:"username" binding:"required,max=255"`
 		Password string `json:"password" binding:"required"`
 	}
 
@@ -66,15 +88,58 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	// Usernames and addresses with too many failed logins have to wait before trying again. The
+	// attempt counts as failed until the password matched.
+	now := time.Now()
+	attempt, wait, err := loginguard.Logins.Reserve(c.Request.Context(), loginData.Username, c.ClientIP(), now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check login attempts"})
+		return
+	}
+	if wait > 0 {
+		recordActivity(c, models.Activity{
+			Action:     models.ActivityUserLoginFail,
+			TargetType: "user",
+		}, nil, gin.H{"username": loginData.Username, "locked": true})
+		c.Header("Retry-After", retryAfter(wait))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed login attempts"})
+		return
+	}
+
 	var user models.User
 	if err := config.DB.Where("username = ?", loginData.Username).First(&user).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		recordActivity(c, models.Activity{
+			Action:     models.ActivityUserLoginFail,
+			TargetType: "user",
+		}, nil, gin.H{"username": loginData.Username})
+		loginFailed(c, attempt, loginData.Username, nil, now)
 		return
 	}
 
 	// Compare the stored hashed password with the input password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginData.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		recordActivity(c, models.Activity{
+			Action:     models.ActivityUserLoginFail,
+			TargetType: "user",
+			TargetID:   uintPtr(user.ID),
+		}, nil, gin.H{"username": loginData.Username})
+		loginFailed(c, attempt, loginData.Username, uintPtr(user.ID), now)
+		return
+	}
+
+	if err := loginguard.Logins.Succeed(c.Request.Context(), attempt); err != nil {
+		log.Printf("Failed to reset failed logins of %s: %v", loginData.Username, err)
+	}
+
+	if user.Disabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is disabled"})
+		return
+	}
+
+	// Tokens refreshed from this login share its session ID, which scopes unlocked notes
+	sessionID, err := newSessionID()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
 		return
 	}
 
@@ -82,6 +147,8 @@ func Login(c *gin.Context) {
 	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"user_id":  user.ID,
 		"username": user.Username,
+		"sid":      sessionID,
+		"iat":      time.Now().Unix(),
 		"exp":      time.Now().Add(time.Minute * 15).Unix(), // Access token expires in 15 minutes
 	})
 
@@ -95,6 +162,8 @@ func Login(c *gin.Context) {
 	// Generate refresh token (long-lived)
 	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"user_id": user.ID,
+		"sid":     sessionID,
+		"iat":     time.Now().Unix(),
 		"exp":     time.Now().Add(time.Hour * 24 * 7).Unix(), // Refresh token expires in 7 days
 	})
 
@@ -109,6 +178,13 @@ func Login(c *gin.Context) {
 	c.SetCookie("access_token", accessTokenString, 15*60, "/", "localhost", false, true) // Expires in 15 minutes
 	c.SetCookie("refresh_token", refreshTokenString, 7*24*60*60, "/", "localhost", false, false)
 
+	recordActivity(c, models.Activity{
+		ActorID:    uintPtr(user.ID),
+		Action:     models.ActivityUserLogin,
+		TargetType: "user",
+		TargetID:   uintPtr(user.ID),
+	}, nil, nil)
+
 	// Respond with both tokens
 	c.JSON(http.StatusOK, gin.H{
 		"access_token":  accessTokenString,
@@ -157,11 +233,23 @@ func RefreshToken(c *gin.Context) {
 		}
 	}
 
-	// Generate a new access token
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	// Disabled accounts and revoked sessions cannot get new access tokens
+	user, err := FindUserByID(userID)
+	if err != nil || user.Disabled || user.TokenRevoked(middleware.TokenIssuedAt(claims)) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	// Generate a new access token, in the session of the refresh token
+	accessClaims := jwt.MapClaims{
 		"user_id": userID,
+		"iat":     time.Now().Unix(),
 		"exp":     time.Now().Add(time.Minute * 15).Unix(), // Access token expires in 15 minutes
-	})
+	}
+	if sessionID, ok := claims["sid"].(string); ok {
+		accessClaims["sid"] = sessionID
+	}
+	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
 
 	// Signing access token
 	accessTokenString, err := accessToken.SignedString([]byte(config.GetJWTSecret()))
@@ -177,6 +265,24 @@ func RefreshToken(c *gin.Context) {
 }
 
 func Logout(c *gin.Context) {
+	// The logout route is public, so the actor is taken from the refresh token if there is one
+	if refreshToken, err := c.Cookie("refresh_token"); err == nil {
+		if userID, ok := userIDFromToken(refreshToken); ok {
+			recordActivity(c, models.Activity{
+				ActorID:    uintPtr(userID),
+				Action:     models.ActivityUserLogout,
+				TargetType: "user",
+				TargetID:   uintPtr(userID),
+			}, nil, nil)
+		}
+		// Notes unlocked in the session are locked again
+		if claims, ok := tokenClaims(refreshToken); ok {
+			if sessionID, ok := claims["sid"].(string); ok {
+				forgetSessionUnlocks(sessionID)
+			}
+		}
+	}
+
 	c.SetCookie("access_token", "", -1, "/", "localhost", false, true)
 	c.SetCookie("refresh_token", "", -1, "/", "localhost", false, false)
 
@@ -207,6 +313,8 @@ func ChangeUsername(c *gin.Context) {
 		return
 	}
 
+	before := gin.H{"username": user.Username}
+
 	user.Username = request.NewUsername
 	if err := config.DB.Save(user).Error; err != nil {
 		// Check for unique constraint violation
@@ -218,10 +326,88 @@ func ChangeUsername(c *gin.Context) {
 		return
 	}
 
+	recordActivity(c, models.Activity{
+		Action:     models.ActivityUserRename,
+		TargetType: "user",
+		TargetID:   uintPtr(user.ID),
+	}, before, gin.H{"username": user.Username})
+
 	c.JSON(http.StatusOK, gin.H{"message": "Username updated successfully"})
 }
 
 // Private helper functions.
+
+// loginFailed records a failed login attempt, records the lockouts it caused and responds with
+// 401. userID is nil when no user has the username.
+func loginFailed(c *gin.Context, attempt loginguard.Attempt, username string, userID *uint, now time.Time) {
+	wait, lockouts, err := loginguard.Logins.Fail(c.Request.Context(), attempt, now)
+	if err != nil {
+		log.Printf("Failed to record failed login of %s: %v", username, err)
+	}
+	for _, lockout := range lockouts {
+		activity := models.Activity{Action: models.ActivityUserLockout, TargetType: "user", TargetID: userID}
+		if lockout.Kind == loginguard.KindIP {
+			activity.TargetType = "ip"
+			activity.TargetID = nil
+		}
+		recordActivity(c, activity, nil, gin.H{
+			"username": username,
+			"key":      lockout.Kind,
+			"failures": lockout.Failures,
+			"until":    lockout.Until,
+		})
+	}
+
+	if wait > 0 {
+		c.Header("Retry-After", retryAfter(wait))
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+}
+
+// retryAfter formats a wait as the whole seconds of a Retry-After header, rounded up.
+func retryAfter(wait time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(wait.Seconds())))
+}
+
 func isUniqueConstraintError(err error) bool {
 	return err != nil && err.Error() == `ERROR: duplicate key value violates unique constraint "uni_users_username" (SQLSTATE 23505)`
 }
+
+// newSessionID returns a random ID for the session started by a login.
+func newSessionID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// tokenClaims validates a JWT and returns its claims.
+func tokenClaims(tokenString string) (jwt.MapClaims, bool) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return []byte(config.GetJWTSecret()), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, false
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	return claims, ok
+}
+
+// userIDFromToken validates a JWT and returns the user ID it was issued for.
+func userIDFromToken(tokenString string) (uint, bool) {
+	claims, ok := tokenClaims(tokenString)
+	if !ok {
+		return 0, false
+	}
+
+	switch userID := claims["user_id"].(type) {
+	case float64:
+		return uint(userID), true
+	case string:
+		parsed, err := strconv.ParseUint(userID, 10, 32)
+		return uint(parsed), err == nil
+	}
+	return 0, false
+}
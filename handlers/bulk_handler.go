@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/models"
+)
+
+// Bulk request modes.
+const (
+	bulkModeAllOrNothing = "all_or_nothing"
+	bulkModePerItem      = "per_item"
+)
+
+var errBulkRolledBack = errors.New("bulk operation rolled back")
+
+type bulkOperation struct {
+	Op         string  `json:"op"`
+	ID         int     `json:"id"`
+	Title      *string `json:"title"`
+	Content    *string `json:"content"`
+	NotebookID uint    `json:"notebook_id"`
+}
+
+type bulkResult struct {
+	Index  int          `json:"index"`
+	Op     string       `json:"op"`
+	Status int          `json:"status"`
+	Data   *models.Note `json:"data,omitempty"`
+	Error  string       `json:"error,omitempty"`
+}
+
+// bulkEffect is what a successful operation leaves behind once the transaction is committed.
+type bulkEffect struct {
+	activity        models.Activity
+	before, after   interface{}
+	note            *models.Note
+	previousContent string
+	previousTitle   string
+	// attachmentHashes are the contents to prune once a deleted note is committed
+	attachmentHashes []string
+}
+
+// BulkNotes executes a list of create/update/delete/move operations in a single transaction.
+// In all_or_nothing mode (the default) any failure rolls back every operation, in per_item
+// mode only the failing operations are rolled back. Notes have no tags, so "tag" is rejected
+// like any other unknown operation until they do.
+func BulkNotes(c *gin.Context) {
+	var input struct {
+		Mode       string          `json:"mode"`
+		Operations []bulkOperation `json:"operations" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Mode == "" {
+		input.Mode = bulkModeAllOrNothing
+	}
+	if input.Mode != bulkModeAllOrNothing && input.Mode != bulkModePerItem {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Mode must be all_or_nothing or per_item"})
+		return
+	}
+
+	maxOperations := config.GetBulkMaxOperations()
+	if len(input.Operations) > maxOperations {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("A bulk request may contain at most %d operations", maxOperations)})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+	userIDUint, err := strconv.ParseUint(userID.(string), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	var creates int64
+	for _, op := range input.Operations {
+		if op.Op == "create" {
+			creates++
+		}
+	}
+	results := make([]bulkResult, len(input.Operations))
+	effects := make([]*bulkEffect, len(input.Operations))
+	failed := false
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if creates > 0 && !enforceQuota(c, tx, userID, quotaNotes, creates) {
+			return errQuotaExceeded
+		}
+		for i, op := range input.Operations {
+			results[i] = bulkResult{Index: i, Op: op.Op}
+
+			if failed && input.Mode == bulkModeAllOrNothing {
+				results[i].Status = http.StatusFailedDependency
+				results[i].Error = "Not executed because an earlier operation failed"
+				continue
+			}
+
+			savepoint := fmt.Sprintf("bulk_item_%d", i)
+			if err := tx.SavePoint(savepoint).Error; err != nil {
+				return err
+			}
+
+			effect, status, opErr := applyBulkOperation(tx, uint(userIDUint), c.GetUint("workspace_id"), op)
+			results[i].Status = status
+			if opErr != nil {
+				results[i].Error = opErr.Error()
+				failed = true
+				if err := tx.RollbackTo(savepoint).Error; err != nil {
+					return err
+				}
+				continue
+			}
+
+			results[i].Data = effect.note
+			effects[i] = effect
+		}
+
+		if failed && input.Mode == bulkModeAllOrNothing {
+			return errBulkRolledBack
+		}
+		return nil
+	})
+
+	if errors.Is(err, errQuotaExceeded) {
+		return
+	}
+	if err != nil && !errors.Is(err, errBulkRolledBack) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to execute bulk operation"})
+		return
+	}
+
+	if errors.Is(err, errBulkRolledBack) {
+		for i := range results {
+			if results[i].Error == "" {
+				results[i].Status = http.StatusFailedDependency
+				results[i].Error = "Rolled back because another operation failed"
+				results[i].Data = nil
+			}
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bulk operation rolled back", "mode": input.Mode, "results": results})
+		return
+	}
+
+	for _, effect := range effects {
+		if effect == nil {
+			continue
+		}
+		recordActivity(c, effect.activity, effect.before, effect.after)
+		pruneAttachmentBlobs(effect.attachmentHashes)
+		if effect.note != nil && effect.activity.Action != models.ActivityNoteDelete {
+			notifyMentions(*effect.note, effect.previousContent, uint(userIDUint))
+			afterNoteSaved(*effect.note, effect.previousTitle)
+		}
+	}
+
+	status := http.StatusOK
+	if failed {
+		status = http.StatusMultiStatus
+	}
+	c.JSON(status, gin.H{"mode": input.Mode, "results": results})
+}
+
+// Private helper functions.
+
+// applyBulkOperation executes a single operation inside the transaction and returns the
+// HTTP status describing its outcome.
+func applyBulkOperation(tx *gorm.DB, userID uint, workspaceID uint, op bulkOperation) (*bulkEffect, int, error) {
+	switch op.Op {
+	case "create":
+		if op.Title == nil || op.Content == nil || op.NotebookID == 0 {
+			return nil, http.StatusBadRequest, errors.New("title, content and notebook_id are required")
+		}
+		if !notebookInWorkspace(tx, workspaceID, op.NotebookID) {
+			return nil, http.StatusNotFound, errors.New("Notebook not found or access denied")
+		}
+		if notebookIsEncrypted(tx, op.NotebookID) {
+			return nil, http.StatusUnprocessableEntity, errors.New(errEncryptedNotebook)
+		}
+
+		note := models.Note{Title: *op.Title, Content: *op.Content, NotebookID: op.NotebookID, UserID: userID}
+		if err := tx.Create(&note).Error; err != nil {
+			return nil, http.StatusInternalServerError, errors.New("Failed to create note")
+		}
+
+		return &bulkEffect{
+			activity: models.Activity{Action: models.ActivityNoteCreate, TargetType: "note", TargetID: uintPtr(uint(note.ID)), NotebookID: uintPtr(note.NotebookID)},
+			after:    noteSummary(note),
+			note:     &note,
+		}, http.StatusCreated, nil
+
+	case "update", "delete", "move":
+		var note models.Note
+		if err := tx.Scopes(workspaceScope(workspaceID)).Where("id = ?", op.ID).First(&note).Error; err != nil {
+			return nil, http.StatusNotFound, errors.New("Note not found or access denied")
+		}
+		original := note
+		before := noteSummary(note)
+		previousContent, previousTitle := note.Content, note.Title
+
+		// Encrypted notes can only be deleted here, by a holder of the key: the rest needs a
+		// client holding the key
+		if note.KeyVersion > 0 && op.Op != "delete" {
+			return nil, http.StatusUnprocessableEntity, errors.New(errEncryptedNotebook)
+		}
+		if op.Op == "delete" && !holdsNoteKey(tx, note, userID) {
+			return nil, http.StatusNotFound, errors.New("Note not found or access denied")
+		}
+		if note.Locked && op.Op == "update" {
+			return nil, http.StatusLocked, errors.New("Note is locked")
+		}
+
+		switch op.Op {
+		case "update":
+			if op.Title != nil {
+				note.Title = *op.Title
+			}
+			if op.Content != nil {
+				note.Content = *op.Content
+			}
+			if err := tx.Save(&note).Error; err != nil {
+				return nil, http.StatusInternalServerError, errors.New("Failed to update note")
+			}
+			return &bulkEffect{
+				activity:        models.Activity{Action: models.ActivityNoteUpdate, TargetType: "note", TargetID: uintPtr(uint(note.ID)), NotebookID: uintPtr(note.NotebookID)},
+				before:          before,
+				after:           noteUpdateSummary(original, note),
+				note:            &note,
+				previousContent: previousContent,
+				previousTitle:   previousTitle,
+			}, http.StatusOK, nil
+
+		case "delete":
+			hashes := attachmentHashes(tx, []int{note.ID})
+			if err := tx.Delete(&note).Error; err != nil {
+				return nil, http.StatusInternalServerError, errors.New("Failed to delete note")
+			}
+			return &bulkEffect{
+				activity:         models.Activity{Action: models.ActivityNoteDelete, TargetType: "note", TargetID: uintPtr(uint(note.ID)), NotebookID: uintPtr(note.NotebookID)},
+				before:           before,
+				attachmentHashes: hashes,
+			}, http.StatusOK, nil
+
+		default:
+			if op.NotebookID == 0 {
+				return nil, http.StatusBadRequest, errors.New("notebook_id is required")
+			}
+			if !notebookInWorkspace(tx, workspaceID, op.NotebookID) {
+				return nil, http.StatusNotFound, errors.New("Destination notebook not found or access denied")
+			}
+			if notebookIsEncrypted(tx, op.NotebookID) {
+				return nil, http.StatusUnprocessableEntity, errors.New(errEncryptedNotebook)
+			}
+			note.NotebookID = op.NotebookID
+			if err := tx.Model(&note).Update("notebook_id", op.NotebookID).Error; err != nil {
+				return nil, http.StatusInternalServerError, errors.New("Failed to move note")
+			}
+			return &bulkEffect{
+				activity:        models.Activity{Action: models.ActivityNoteMove, TargetType: "note", TargetID: uintPtr(uint(note.ID)), NotebookID: uintPtr(note.NotebookID)},
+				before:          before,
+				after:           noteSummary(note),
+				note:            &note,
+				previousContent: previousContent,
+			}, http.StatusOK, nil
+		}
+
+	default:
+		return nil, http.StatusBadRequest, fmt.Errorf("Unknown operation %q", op.Op)
+	}
+}
@@ -0,0 +1,314 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/models"
+	"noteapp-framework-backend/reminders"
+)
+
+const (
+	calendarProductID = "-//noteapp//calendar feed//EN"
+	calendarUIDDomain = "noteapp"
+	icalUTCFormat     = "20060102T150405Z"
+	icalLocalFormat   = "20060102T150405"
+	icalDateFormat    = "20060102"
+)
+
+// GetCalendarFeed serves the reminders and task due dates of the user behind the secret token
+// as an iCalendar feed. The route is public: calendar apps subscribe without a JWT.
+func GetCalendarFeed(c *gin.Context) {
+	token, ok := strings.CutSuffix(c.Param("token"), ".ics")
+	if !ok || token == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar not found"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.Where("calendar_token_hash = ?", hashCalendarToken(token)).First(&user).Error; err != nil || user.Disabled {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar not found"})
+		return
+	}
+
+	// Only notes of the workspaces the user is still a member of are listed
+	var userReminders []models.Reminder
+	if err := config.DB.Where("user_id = ? AND note_id IN ("+memberNoteIDs+")", user.ID, user.ID).Order("id").Find(&userReminders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reminders"})
+		return
+	}
+
+	var tasks []models.Task
+	if err := config.DB.Where("user_id = ? AND due_date IS NOT NULL AND note_id IN ("+memberNoteIDs+")", user.ID, user.ID).Order("id").Find(&tasks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tasks"})
+		return
+	}
+
+	var noteIDs []uint
+	for _, reminder := range userReminders {
+		noteIDs = append(noteIDs, reminder.NoteID)
+	}
+	for _, task := range tasks {
+		noteIDs = append(noteIDs, task.NoteID)
+	}
+	var notes []models.Note
+	if len(noteIDs) > 0 {
+		if err := config.DB.Select("id", "title").Where("id IN ?", noteIDs).Find(&notes).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notes"})
+			return
+		}
+	}
+	noteTitles := make(map[uint]string, len(notes))
+	for _, note := range notes {
+		noteTitles[uint(note.ID)] = note.Title
+	}
+
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(renderCalendar(userReminders, tasks, noteTitles, time.Now())))
+}
+
+// RegenerateCalendarToken creates a new secret calendar URL for the user. The previous URL
+// stops working, which revokes every existing subscription. The token is only shown once.
+func RegenerateCalendarToken(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate calendar token"})
+		return
+	}
+	token := hex.EncodeToString(secret)
+
+	if err := config.DB.Model(&models.User{}).Where("id = ?", userID).Update("calendar_token_hash", hashCalendarToken(token)).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save calendar token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"token": token, "url": "/calendar/" + token + ".ics"})
+}
+
+// DeleteCalendarToken disables the calendar feed of the user
+func DeleteCalendarToken(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	if err := config.DB.Model(&models.User{}).Where("id = ?", userID).Update("calendar_token_hash", nil).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable calendar feed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Calendar feed disabled successfully"})
+}
+
+// Private helper functions.
+
+// hashCalendarToken returns the SHA-256 of a token; only the hash is stored.
+func hashCalendarToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// renderCalendar builds an RFC 5545 calendar with a VEVENT per reminder and a VTODO per task with a due date.
+func renderCalendar(userReminders []models.Reminder, tasks []models.Task, noteTitles map[uint]string, now time.Time) string {
+	var b strings.Builder
+	stamp := now.UTC().Format(icalUTCFormat)
+
+	writeICalLine(&b, "BEGIN:VCALENDAR")
+	writeICalLine(&b, "VERSION:2.0")
+	writeICalLine(&b, "PRODID:"+calendarProductID)
+	writeICalLine(&b, "CALSCALE:GREGORIAN")
+
+	// Every TZID needs a VTIMEZONE describing it
+	locations := make(map[int]*time.Location)
+	written := make(map[string]bool)
+	for _, reminder := range userReminders {
+		if location, err := time.LoadLocation(reminder.Timezone); err == nil && reminder.RRule != "" && location != time.UTC {
+			locations[reminder.ID] = location
+			if !written[location.String()] {
+				writeVTimezone(&b, location, now)
+				written[location.String()] = true
+			}
+		}
+	}
+
+	for _, reminder := range userReminders {
+		summary := reminder.Message
+		if summary == "" {
+			summary = "Reminder: " + noteTitles[reminder.NoteID]
+		}
+
+		writeICalLine(&b, "BEGIN:VEVENT")
+		writeICalLine(&b, fmt.Sprintf("UID:reminder-%d@%s", reminder.ID, calendarUIDDomain))
+		writeICalLine(&b, "DTSTAMP:"+stamp)
+		if location, ok := locations[reminder.ID]; ok {
+			// Recurrences follow the wall clock of the user's time zone
+			writeICalLine(&b, "DTSTART;TZID="+location.String()+":"+reminder.StartsAt.In(location).Format(icalLocalFormat))
+		} else {
+			writeICalLine(&b, "DTSTART:"+reminder.StartsAt.UTC().Format(icalUTCFormat))
+		}
+		if reminder.RRule != "" {
+			if rule, err := reminders.ParseRule(reminder.RRule); err == nil {
+				writeICalLine(&b, "RRULE:"+rule.String())
+			}
+		}
+		writeICalLine(&b, "SUMMARY:"+icalEscape(summary))
+		writeICalLine(&b, "DESCRIPTION:"+icalEscape(noteTitles[reminder.NoteID]))
+		writeICalLine(&b, "END:VEVENT")
+	}
+
+	for _, task := range tasks {
+		status := "NEEDS-ACTION"
+		if task.Done {
+			status = "COMPLETED"
+		}
+		summary := strings.TrimSpace(dueDatePattern.ReplaceAllString(task.Text, ""))
+
+		writeICalLine(&b, "BEGIN:VTODO")
+		writeICalLine(&b, fmt.Sprintf("UID:task-%d@%s", task.ID, calendarUIDDomain))
+		writeICalLine(&b, "DTSTAMP:"+stamp)
+		writeICalLine(&b, "DUE;VALUE=DATE:"+task.DueDate.Format(icalDateFormat))
+		writeICalLine(&b, "SUMMARY:"+icalEscape(summary))
+		writeICalLine(&b, "DESCRIPTION:"+icalEscape(noteTitles[task.NoteID]))
+		writeICalLine(&b, "STATUS:"+status)
+		writeICalLine(&b, "END:VTODO")
+	}
+
+	writeICalLine(&b, "END:VCALENDAR")
+	return b.String()
+}
+
+// writeVTimezone writes the VTIMEZONE of a TZID. Its observances repeat every year the daylight
+// saving time transitions of the zone in the year of now; other zones get their current offset.
+func writeVTimezone(b *strings.Builder, location *time.Location, now time.Time) {
+	writeICalLine(b, "BEGIN:VTIMEZONE")
+	writeICalLine(b, "TZID:"+location.String())
+
+	transitions := zoneTransitions(location, now.Year())
+	if len(transitions) != 2 {
+		name, offset := now.In(location).Zone()
+		writeTimezoneObservance(b, "STANDARD", name, offset, offset, "19700101T000000", "")
+	} else {
+		for _, at := range transitions {
+			_, from := at.Add(-time.Second).In(location).Zone()
+			name, to := at.In(location).Zone()
+			kind := "STANDARD"
+			if at.In(location).IsDST() {
+				kind = "DAYLIGHT"
+			}
+
+			// Observances start at the wall clock time before the transition, on the same weekday of the month
+			local := at.Add(time.Duration(from) * time.Second).UTC()
+			week := (local.Day()-1)/7 + 1
+			if local.Day()+7 > time.Date(local.Year(), local.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day() {
+				week = -1
+			}
+			start := nthWeekday(1970, local.Month(), local.Weekday(), week)
+			writeTimezoneObservance(b, kind, name, from, to,
+				start.Format(icalDateFormat)+"T"+local.Format("150405"),
+				fmt.Sprintf("FREQ=YEARLY;BYMONTH=%d;BYDAY=%d%s", local.Month(), week, strings.ToUpper(local.Weekday().String()[:2])))
+		}
+	}
+
+	writeICalLine(b, "END:VTIMEZONE")
+}
+
+func writeTimezoneObservance(b *strings.Builder, kind, name string, from, to int, start, rrule string) {
+	writeICalLine(b, "BEGIN:"+kind)
+	writeICalLine(b, "TZNAME:"+icalEscape(name))
+	writeICalLine(b, "TZOFFSETFROM:"+icalOffset(from))
+	writeICalLine(b, "TZOFFSETTO:"+icalOffset(to))
+	writeICalLine(b, "DTSTART:"+start)
+	if rrule != "" {
+		writeICalLine(b, "RRULE:"+rrule)
+	}
+	writeICalLine(b, "END:"+kind)
+}
+
+// zoneTransitions returns the instants the UTC offset of the location changes during the year.
+func zoneTransitions(location *time.Location, year int) []time.Time {
+	var transitions []time.Time
+	start := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(1, 0, 0)
+	_, offset := start.In(location).Zone()
+	for t := start; t.Before(end); t = t.Add(time.Hour) {
+		next := t.Add(time.Hour)
+		_, nextOffset := next.In(location).Zone()
+		if nextOffset == offset {
+			continue
+		}
+		// Narrow the change down to the second
+		before, after := t, next
+		for after.Sub(before) > time.Second {
+			middle := before.Add(after.Sub(before) / 2)
+			if _, middleOffset := middle.In(location).Zone(); middleOffset == offset {
+				before = middle
+			} else {
+				after = middle
+			}
+		}
+		transitions = append(transitions, after)
+		offset = nextOffset
+	}
+	return transitions
+}
+
+// nthWeekday returns the nth weekday of the month, counted from its end when n is negative.
+func nthWeekday(year int, month time.Month, weekday time.Weekday, n int) time.Time {
+	if n < 0 {
+		last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC)
+		return last.AddDate(0, 0, 7*(n+1)-(int(last.Weekday())-int(weekday)+7)%7)
+	}
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	return first.AddDate(0, 0, (int(weekday)-int(first.Weekday())+7)%7+7*(n-1))
+}
+
+// icalOffset formats a UTC offset in seconds as a UTC-OFFSET value.
+func icalOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign, seconds = "-", -seconds
+	}
+	offset := fmt.Sprintf("%s%02d%02d", sign, seconds/3600, seconds%3600/60)
+	if seconds%60 != 0 {
+		offset += fmt.Sprintf("%02d", seconds%60)
+	}
+	return offset
+}
+
+// icalEscape escapes a TEXT value.
+func icalEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(value)
+}
+
+// writeICalLine writes a content line, folded at 75 octets without splitting UTF-8 characters.
+func writeICalLine(b *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines start with a space, which counts towards their length
+		limit = 74
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
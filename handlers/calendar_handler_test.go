@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"noteapp-framework-backend/models"
+)
+
+func TestICalEscape(t *testing.T) {
+	assert.Equal(t, `a\, b\; c\\d\ne`, icalEscape("a, b; c\\d\ne"))
+}
+
+func TestWriteICalLineFolds(t *testing.T) {
+	var b strings.Builder
+	writeICalLine(&b, "SUMMARY:"+strings.Repeat("é", 80))
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\r\n"), "\r\n")
+	assert.Greater(t, len(lines), 1)
+	for i, line := range lines {
+		assert.LessOrEqual(t, len(line), 75)
+		if i > 0 {
+			assert.True(t, strings.HasPrefix(line, " "))
+		}
+	}
+	assert.Equal(t, "SUMMARY:"+strings.Repeat("é", 80), strings.ReplaceAll(strings.Join(lines, "\r\n"), "\r\n ", ""))
+}
+
+func TestRenderCalendar(t *testing.T) {
+	due := time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC)
+	userReminders := []models.Reminder{
+		{ID: 1, NoteID: 5, StartsAt: time.Date(2026, 10, 19, 7, 0, 0, 0, time.UTC), RRule: "FREQ=WEEKLY;BYDAY=MO", Timezone: "Europe/Berlin"},
+		{ID: 4, NoteID: 5, StartsAt: time.Date(2026, 10, 20, 7, 0, 0, 0, time.UTC), RRule: "FREQ=DAILY", Timezone: "Europe/Berlin"},
+		{ID: 2, NoteID: 5, Message: "Call Bob", StartsAt: time.Date(2026, 10, 21, 12, 0, 0, 0, time.UTC), Timezone: "UTC"},
+	}
+	tasks := []models.Task{{ID: 3, NoteID: 5, Text: "Write report @due(2026-10-20)", Done: true, DueDate: &due}}
+
+	calendar := renderCalendar(userReminders, tasks, map[uint]string{5: "Weekly sync"}, time.Date(2026, 10, 19, 6, 0, 0, 0, time.UTC))
+
+	assert.True(t, strings.HasPrefix(calendar, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	assert.Contains(t, calendar, "BEGIN:VTIMEZONE\r\nTZID:Europe/Berlin\r\n"+
+		"BEGIN:DAYLIGHT\r\nTZNAME:CEST\r\nTZOFFSETFROM:+0100\r\nTZOFFSETTO:+0200\r\nDTSTART:19700329T020000\r\nRRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU\r\nEND:DAYLIGHT\r\n"+
+		"BEGIN:STANDARD\r\nTZNAME:CET\r\nTZOFFSETFROM:+0200\r\nTZOFFSETTO:+0100\r\nDTSTART:19701025T030000\r\nRRULE:FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU\r\nEND:STANDARD\r\n"+
+		"END:VTIMEZONE\r\n")
+	assert.Equal(t, 1, strings.Count(calendar, "BEGIN:VTIMEZONE"))
+	assert.Contains(t, calendar, "DTSTART;TZID=Europe/Berlin:20261019T090000\r\nRRULE:FREQ=WEEKLY;BYDAY=MO\r\nSUMMARY:Reminder: Weekly sync\r\n")
+	assert.Contains(t, calendar, "DTSTART:20261021T120000Z\r\nSUMMARY:Call Bob\r\n")
+	assert.Contains(t, calendar, "UID:task-3@noteapp\r\nDTSTAMP:20261019T060000Z\r\nDUE;VALUE=DATE:20261020\r\nSUMMARY:Write report\r\n")
+	assert.Contains(t, calendar, "STATUS:COMPLETED\r\nEND:VTODO\r\n")
+	assert.True(t, strings.HasSuffix(calendar, "END:VCALENDAR\r\n"))
+}
+
+func TestWriteVTimezoneWithoutDaylightSavingTime(t *testing.T) {
+	location, err := time.LoadLocation("Asia/Tokyo")
+	assert.NoError(t, err)
+
+	var b strings.Builder
+	writeVTimezone(&b, location, time.Date(2026, 10, 19, 6, 0, 0, 0, time.UTC))
+	assert.Equal(t, "BEGIN:VTIMEZONE\r\nTZID:Asia/Tokyo\r\nBEGIN:STANDARD\r\nTZNAME:JST\r\nTZOFFSETFROM:+0900\r\nTZOFFSETTO:+0900\r\n"+
+		"DTSTART:19700101T000000\r\nEND:STANDARD\r\nEND:VTIMEZONE\r\n", b.String())
+}
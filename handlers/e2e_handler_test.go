@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/models"
+)
+
+func TestCheckWrappedKeys(t *testing.T) {
+	keys := map[string]string{"1": "k1", "3": "k3"}
+
+	missing, unexpected := checkWrappedKeys([]uint{1, 2, 3}, 2, keys)
+	assert.Empty(t, missing)
+	assert.Empty(t, unexpected)
+
+	missing, unexpected = checkWrappedKeys([]uint{1, 2, 3}, 0, keys)
+	assert.Equal(t, []string{"2"}, missing)
+	assert.Empty(t, unexpected)
+
+	// The removed member must not receive the new key
+	missing, unexpected = checkWrappedKeys([]uint{1, 3}, 3, keys)
+	assert.Empty(t, missing)
+	assert.Equal(t, []string{"3"}, unexpected)
+
+	missing, _ = checkWrappedKeys([]uint{1, 3}, 0, map[string]string{"1": "k1", "3": ""})
+	assert.Equal(t, []string{"3"}, missing)
+}
+
+func TestRejectEncrypted(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	assert.False(t, rejectEncrypted(c, models.Notebook{}))
+	assert.Equal(t, 0, w.Body.Len())
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	assert.True(t, rejectEncrypted(c, models.Notebook{Encrypted: true, KeyVersion: 1}))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+func TestRemoveCreatorThenRotate(t *testing.T) {
+	initNoteTestDB()
+	config.DB.Exec("INSERT INTO users (id, username, password) VALUES (2, 'Other', 'password')")
+	config.DB.Exec("INSERT INTO workspaces (id, name) VALUES (2, 'Team')")
+	config.DB.Exec("INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (2, 1, 'admin'), (2, 2, 'admin')")
+	config.DB.Create(&models.Notebook{ID: 5, Name: "Secrets", UserID: 1, WorkspaceID: 2, Encrypted: true, KeyVersion: 1})
+	config.DB.Create(&[]models.NotebookKey{
+		{NotebookID: 5, UserID: 1, KeyVersion: 1, WrappedKey: "k1"},
+		{NotebookID: 5, UserID: 2, KeyVersion: 1, WrappedKey: "k2"},
+	})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", c.GetHeader("X-Test-User"))
+		c.Set("workspace_id", uint(2))
+	})
+	router.DELETE("/workspaces/:id/members/:userId", RemoveWorkspaceMember)
+	router.POST("/notebooks/:id/rotate-key", RotateNotebookKey)
+	request := func(method, path, userID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Test-User", userID)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// The creator leaves, and the notebook passes to the other key holder
+	w := request("DELETE", "/workspaces/2/members/1", "1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var notebook models.Notebook
+	config.DB.First(&notebook, 5)
+	assert.Equal(t, uint(2), notebook.UserID)
+	assert.True(t, notebook.RotationRequired)
+
+	w = request("POST", "/notebooks/5/rotate-key", "2", `{"wrapped_keys": {"2": "k2v2"}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	config.DB.First(&notebook, 5)
+	assert.Equal(t, 2, notebook.KeyVersion)
+	assert.False(t, notebook.RotationRequired)
+
+	// The last key holder cannot leave while the notebook exists
+	config.DB.Exec("INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (2, 1, 'admin')")
+	w = request("DELETE", "/workspaces/2/members/2", "2", "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/models"
+)
+
+type graphNode struct {
+	ID    string `json:"id"`
+	Type  string `json:"type"`
+	Label string `json:"label"`
+}
+
+type graphEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Type   string `json:"type"`
+}
+
+type graph struct {
+	Nodes []graphNode `json:"nodes"`
+	Edges []graphEdge `json:"edges"`
+}
+
+// GetGraph retrieves the knowledge graph of the active workspace: notebooks and notes as nodes, with
+// "contains" edges from notebooks to their notes and sub-notebooks and "links" edges between
+// linked notes. ?notebook_id= scopes the graph to a notebook and its sub-notebooks and
+// ?format=graphml|dot exports it for external tools instead of returning JSON.
+func GetGraph(c *gin.Context) {
+	_, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "graphml" && format != "dot" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be json, graphml or dot"})
+		return
+	}
+
+	var notebooks []models.Notebook
+	if notebookID := c.Query("notebook_id"); notebookID != "" {
+		var root models.Notebook
+		if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", notebookID).First(&root).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Notebook not found or access denied"})
+			return
+		}
+		subtree, _, err := notebookSubtree(root)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sub-notebooks"})
+			return
+		}
+		notebooks = subtree
+	} else if err := config.DB.Scopes(inWorkspace(c)).Where("archived = ?", false).Order("id").Find(&notebooks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notebooks"})
+		return
+	}
+
+	notebookIDs := make([]int, 0, len(notebooks))
+	for _, notebook := range notebooks {
+		notebookIDs = append(notebookIDs, notebook.ID)
+	}
+
+	// Titles of end-to-end encrypted notes are ciphertext, so those notes are left out
+	var notes []models.Note
+	if err := config.DB.Select("id", "title", "notebook_id").
+		Scopes(inWorkspace(c)).
+		Where("archived = ? AND key_version = ? AND notebook_id IN ?", false, 0, notebookIDs).
+		Order("id").
+		Find(&notes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notes"})
+		return
+	}
+
+	noteIDs := make([]int, 0, len(notes))
+	for _, note := range notes {
+		noteIDs = append(noteIDs, note.ID)
+	}
+
+	var links []models.NoteLink
+	if err := config.DB.Where("source_note_id IN ? AND target_note_id IN ?", noteIDs, noteIDs).Order("id").Find(&links).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch links"})
+		return
+	}
+
+	g := buildGraph(notebooks, notes, links)
+
+	switch format {
+	case "graphml":
+		c.Header("Content-Disposition", "attachment; filename=graph.graphml")
+		c.Data(http.StatusOK, "application/graphml+xml", []byte(g.graphML()))
+	case "dot":
+		c.Header("Content-Disposition", "attachment; filename=graph.dot")
+		c.Data(http.StatusOK, "text/vnd.graphviz", []byte(g.dot()))
+	default:
+		c.JSON(http.StatusOK, gin.H{"data": g})
+	}
+}
+
+// Private helper functions.
+
+func buildGraph(notebooks []models.Notebook, notes []models.Note, links []models.NoteLink) graph {
+	g := graph{Nodes: []graphNode{}, Edges: []graphEdge{}}
+
+	inGraph := make(map[int]bool, len(notebooks))
+	for _, notebook := range notebooks {
+		inGraph[notebook.ID] = true
+		g.Nodes = append(g.Nodes, graphNode{ID: notebookNodeID(notebook.ID), Type: "notebook", Label: notebook.Name})
+	}
+	for _, notebook := range notebooks {
+		if notebook.ParentID != nil && inGraph[int(*notebook.ParentID)] {
+			g.Edges = append(g.Edges, graphEdge{Source: notebookNodeID(int(*notebook.ParentID)), Target: notebookNodeID(notebook.ID), Type: "contains"})
+		}
+	}
+
+	for _, note := range notes {
+		g.Nodes = append(g.Nodes, graphNode{ID: noteNodeID(note.ID), Type: "note", Label: note.Title})
+		g.Edges = append(g.Edges, graphEdge{Source: notebookNodeID(int(note.NotebookID)), Target: noteNodeID(note.ID), Type: "contains"})
+	}
+
+	for _, link := range links {
+		g.Edges = append(g.Edges, graphEdge{Source: noteNodeID(int(link.SourceNoteID)), Target: noteNodeID(int(*link.TargetNoteID)), Type: "links"})
+	}
+
+	return g
+}
+
+func notebookNodeID(id int) string {
+	return fmt.Sprintf("notebook-%d", id)
+}
+
+func noteNodeID(id int) string {
+	return fmt.Sprintf("note-%d", id)
+}
+
+// graphML renders the graph as GraphML, with the node type/label and edge type as data keys.
+func (g graph) graphML() string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n")
+	b.WriteString(`  <key id="type" for="all" attr.name="type" attr.type="string"/>` + "\n")
+	b.WriteString(`  <key id="label" for="node" attr.name="label" attr.type="string"/>` + "\n")
+	b.WriteString(`  <graph id="notes" edgedefault="directed">` + "\n")
+
+	for _, node := range g.Nodes {
+		fmt.Fprintf(&b, "    <node id=\"%s\">\n", xmlEscape(node.ID))
+		fmt.Fprintf(&b, "      <data key=\"type\">%s</data>\n", xmlEscape(node.Type))
+		fmt.Fprintf(&b, "      <data key=\"label\">%s</data>\n", xmlEscape(node.Label))
+		b.WriteString("    </node>\n")
+	}
+	for i, edge := range g.Edges {
+		fmt.Fprintf(&b, "    <edge id=\"e%d\" source=\"%s\" target=\"%s\">\n", i, xmlEscape(edge.Source), xmlEscape(edge.Target))
+		fmt.Fprintf(&b, "      <data key=\"type\">%s</data>\n", xmlEscape(edge.Type))
+		b.WriteString("    </edge>\n")
+	}
+
+	b.WriteString("  </graph>\n</graphml>\n")
+	return b.String()
+}
+
+// dot renders the graph in the Graphviz DOT language. Notebooks are drawn as folders and
+// "contains" edges dashed, so the note links stand out.
+func (g graph) dot() string {
+	var b strings.Builder
+	b.WriteString("digraph notes {\n")
+
+	for _, node := range g.Nodes {
+		shape := "box"
+		if node.Type == "notebook" {
+			shape = "folder"
+		}
+		fmt.Fprintf(&b, "  %s [label=%s, shape=%s];\n", dotQuote(node.ID), dotQuote(node.Label), shape)
+	}
+	for _, edge := range g.Edges {
+		style := "solid"
+		if edge.Type == "contains" {
+			style = "dashed"
+		}
+		fmt.Fprintf(&b, "  %s -> %s [style=%s];\n", dotQuote(edge.Source), dotQuote(edge.Target), style)
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func xmlEscape(value string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
+
+func dotQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"noteapp-framework-backend/models"
+)
+
+func TestBuildGraph(t *testing.T) {
+	parentID := uint(1)
+	targetID := uint(11)
+	notebooks := []models.Notebook{{ID: 1, Name: "Work"}, {ID: 2, Name: "Meetings", ParentID: &parentID}}
+	notes := []models.Note{{ID: 10, Title: `Say "hi"`, NotebookID: 1}, {ID: 11, Title: "Plan", NotebookID: 2}}
+	links := []models.NoteLink{{SourceNoteID: 10, TargetNoteID: &targetID}}
+
+	g := buildGraph(notebooks, notes, links)
+
+	assert.Len(t, g.Nodes, 4)
+	assert.Contains(t, g.Edges, graphEdge{Source: "notebook-1", Target: "notebook-2", Type: "contains"})
+	assert.Contains(t, g.Edges, graphEdge{Source: "note-10", Target: "note-11", Type: "links"})
+	assert.Contains(t, g.dot(), `"note-10" [label="Say \"hi\"", shape=box];`)
+	assert.Contains(t, g.graphML(), `<data key="label">Say &#34;hi&#34;</data>`)
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/images"
+	"noteapp-framework-backend/models"
+	"noteapp-framework-backend/storage"
+)
+
+// originalImage is the name under which the uploaded image itself is served.
+const originalImage = "original"
+
+// exportImageVariant is the copy of an image embedded in PDF exports.
+const exportImageVariant = "medium.jpg"
+
+// inlineImagePattern matches the Markdown images of a note that refer to an attachment: ![alt](attachment:12).
+var inlineImagePattern = regexp.MustCompile(`!\[([^\]]*)\]\(attachment:(\d+)\)`)
+
+// GetImageURL returns a signed, short-lived URL of an image attachment (?variant=original,
+// thumb.jpg, thumb.webp, medium.jpg or medium.webp) that can be used in an <img> tag.
+func GetImageURL(c *gin.Context) {
+	_, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var attachment models.Attachment
+	if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", c.Param("id")).First(&attachment).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found or access denied"})
+		return
+	}
+	if !images.IsImage(attachment.ContentType) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Attachment is not an image"})
+		return
+	}
+
+	variant := c.DefaultQuery("variant", originalImage)
+	if _, ok := images.FindVariant(variant); !ok && variant != originalImage {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown image variant"})
+		return
+	}
+
+	expiresAt := time.Now().Add(config.GetImageURLTTL())
+	c.JSON(http.StatusOK, gin.H{"url": signedImageURL(attachment.ID, variant, c.GetString("user_id"), expiresAt), "expires_at": expiresAt.UTC()})
+}
+
+// GetImage serves an image through a signed URL. The route is public so browsers can load it
+// without an Authorization header; the signature and its expiry take the place of the JWT, and
+// the URLs of users disabled since stop working.
+func GetImage(c *gin.Context) {
+	id, variant, userID := c.Param("id"), c.Param("variant"), c.Query("user")
+
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires || !hmac.Equal([]byte(c.Query("signature")), []byte(imageSignature(id, variant, userID, expires))) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid or expired image URL"})
+		return
+	}
+	var user models.User
+	if err := config.DB.Select("id", "disabled").Where("id = ?", userID).First(&user).Error; err != nil || user.Disabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid or expired image URL"})
+		return
+	}
+
+	var attachment models.Attachment
+	if err := config.DB.Where("id = ?", id).First(&attachment).Error; err != nil || !images.IsImage(attachment.ContentType) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return
+	}
+
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", expires-time.Now().Unix()))
+	if variant == originalImage {
+		serveAttachment(c, attachment, "inline")
+		return
+	}
+
+	imageVariant, ok := images.FindVariant(variant)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return
+	}
+	data, err := readImageVariant(c, attachment, variant)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read image"})
+		return
+	}
+
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, imageVariant.ContentType(), data)
+}
+
+// Private helper functions.
+
+// imageVariantKey is the storage key of a variant of the image content with the given SHA-256.
+func imageVariantKey(sum, variant string) string {
+	return attachmentKey(sum) + "." + variant
+}
+
+// storeImageAttachment strips the metadata of an uploaded image, then stores it and its
+// variants unless the same image is stored already, holding the lock of its hash in tx. It
+// fills in the hash and dimensions.
+func storeImageAttachment(c *gin.Context, tx *gorm.DB, r io.Reader, attachment *models.Attachment) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	data, attachment.Width, attachment.Height, err = images.Sanitize(data, attachment.ContentType)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(data)
+	attachment.SHA256 = hex.EncodeToString(sum[:])
+	attachment.Size = int64(len(data))
+
+	if err := lockAttachmentHash(tx, attachment.SHA256); err != nil {
+		return err
+	}
+	stored, err := storage.Files.Exists(c.Request.Context(), attachmentKey(attachment.SHA256))
+	if err != nil || stored {
+		return err
+	}
+
+	rendered, err := images.Render(data, images.Variants)
+	if err != nil {
+		return err
+	}
+	for _, variant := range images.Variants {
+		encoded := rendered[variant.File()]
+		if err := storage.Files.Put(c.Request.Context(), imageVariantKey(attachment.SHA256, variant.File()), bytes.NewReader(encoded), int64(len(encoded)), variant.ContentType()); err != nil {
+			return err
+		}
+	}
+
+	// The original goes last: its presence marks the image and its variants as complete
+	return storage.Files.Put(c.Request.Context(), attachmentKey(attachment.SHA256), bytes.NewReader(data), attachment.Size, attachment.ContentType)
+}
+
+// readImageVariant reads a variant of an image. Variants missing from the storage, such as
+// those of images uploaded before variants existed, are rendered from the original and stored.
+func readImageVariant(c *gin.Context, attachment models.Attachment, name string) ([]byte, error) {
+	ctx := c.Request.Context()
+
+	content, err := storage.Files.Get(ctx, imageVariantKey(attachment.SHA256, name), 0, -1)
+	if err == nil {
+		defer content.Close()
+		return io.ReadAll(content)
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return nil, err
+	}
+
+	variant, ok := images.FindVariant(name)
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	original, err := storage.Files.Get(ctx, attachmentKey(attachment.SHA256), 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	defer original.Close()
+	data, err := io.ReadAll(original)
+	if err != nil {
+		return nil, err
+	}
+
+	rendered, err := images.Render(data, []images.Variant{variant})
+	if err != nil {
+		return nil, err
+	}
+	encoded := rendered[name]
+	if err := storage.Files.Put(ctx, imageVariantKey(attachment.SHA256, name), bytes.NewReader(encoded), int64(len(encoded)), variant.ContentType()); err != nil {
+		log.Printf("Failed to store variant %s of image %d: %v", name, attachment.ID, err)
+	}
+	return encoded, nil
+}
+
+// signedImageURL builds the public URL of an image variant for the user, valid until expiresAt.
+func signedImageURL(attachmentID int, variant, userID string, expiresAt time.Time) string {
+	id := strconv.Itoa(attachmentID)
+	query := url.Values{}
+	query.Set("user", userID)
+	query.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+	query.Set("signature", imageSignature(id, variant, userID, expiresAt.Unix()))
+	return "/images/" + id + "/" + variant + "?" + query.Encode()
+}
+
+func imageSignature(id, variant, userID string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(config.GetImageURLSecret()))
+	fmt.Fprintf(mac, "%s/%s/%s/%d", id, variant, userID, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// noteExportImages loads the images referenced in the content for the PDF export, as base64
+// JPEG keyed by attachment ID. Images of other workspaces or that cannot be read are left out.
+func noteExportImages(c *gin.Context, content string) map[string]string {
+	exported := make(map[string]string)
+
+	for _, match := range inlineImagePattern.FindAllStringSubmatch(content, -1) {
+		id := match[2]
+		if _, done := exported[id]; done {
+			continue
+		}
+
+		var attachment models.Attachment
+		if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", id).First(&attachment).Error; err != nil || !images.IsImage(attachment.ContentType) {
+			continue
+		}
+		data, err := readImageVariant(c, attachment, exportImageVariant)
+		if err != nil {
+			log.Printf("Failed to read image %d for export: %v", attachment.ID, err)
+			continue
+		}
+		exported[id] = base64.StdEncoding.EncodeToString(data)
+	}
+
+	return exported
+}
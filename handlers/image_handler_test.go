@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignedImageURL(t *testing.T) {
+	t.Setenv("IMAGE_URL_SECRET", "test-secret")
+	expiresAt := time.Now().Add(time.Minute)
+
+	signed, err := url.Parse(signedImageURL(12, "thumb.webp", "3", expiresAt))
+	assert.NoError(t, err)
+	assert.Equal(t, "/images/12/thumb.webp", signed.Path)
+	assert.Equal(t, strconv.FormatInt(expiresAt.Unix(), 10), signed.Query().Get("expires"))
+	assert.Equal(t, "3", signed.Query().Get("user"))
+
+	signature := signed.Query().Get("signature")
+	assert.Equal(t, imageSignature("12", "thumb.webp", "3", expiresAt.Unix()), signature)
+	assert.NotEqual(t, imageSignature("12", "original", "3", expiresAt.Unix()), signature)
+	assert.NotEqual(t, imageSignature("13", "thumb.webp", "3", expiresAt.Unix()), signature)
+	assert.NotEqual(t, imageSignature("12", "thumb.webp", "4", expiresAt.Unix()), signature)
+	assert.NotEqual(t, imageSignature("12", "thumb.webp", "3", expiresAt.Unix()+1), signature)
+}
+
+func TestInlineImagePattern(t *testing.T) {
+	matches := inlineImagePattern.FindAllStringSubmatch("![Screenshot](attachment:4) and ![](attachment:17) but not [link](attachment:5)", -1)
+
+	if assert.Len(t, matches, 2) {
+		assert.Equal(t, []string{"![Screenshot](attachment:4)", "Screenshot", "4"}, matches[0])
+		assert.Equal(t, "17", matches[1][2])
+	}
+}
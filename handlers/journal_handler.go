@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/models"
+)
+
+const (
+	journalNotebookName = "Journal"
+	defaultJournalTitle = "{{date}}"
+	journalDateFormat   = "2006-01-02"
+	journalMonthFormat  = "2006-01"
+)
+
+// GetDailyNote fetches the journal note of a date (YYYY-MM-DD or "today" in the user's time zone),
+// creating it from the user's journal template if it does not exist yet. The response also holds
+// the dates of the previous and next existing entries.
+func GetDailyNote(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	user, err := FindUserByID(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+	location := userLocation(user)
+
+	var date time.Time
+	if param := c.Param("date"); param == "today" {
+		now := time.Now().In(location)
+		date = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	} else if date, err = time.Parse(journalDateFormat, param); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Date must be YYYY-MM-DD or today"})
+		return
+	}
+
+	var note models.Note
+	var entry models.JournalEntry
+	err = config.DB.Where("user_id = ? AND entry_date = ?", user.ID, date).First(&entry).Error
+	switch {
+	case err == nil:
+		if err := config.DB.First(&note, entry.NoteID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch daily note"})
+			return
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		var created bool
+		note, created, err = createDailyNote(c, user, date, location)
+		if errors.Is(err, errQuotaExceeded) {
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create daily note"})
+			return
+		}
+		if created {
+			afterNoteCreated(c, note)
+		}
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch daily note"})
+		return
+	}
+
+	var previous, next models.JournalEntry
+	response := gin.H{"data": note, "date": date.Format(journalDateFormat), "previous": nil, "next": nil}
+	if err := config.DB.Where("user_id = ? AND entry_date < ?", user.ID, date).Order("entry_date DESC").Limit(1).Find(&previous).Error; err == nil && previous.NoteID != 0 {
+		response["previous"] = previous.EntryDate.Format(journalDateFormat)
+	}
+	if err := config.DB.Where("user_id = ? AND entry_date > ?", user.ID, date).Order("entry_date").Limit(1).Find(&next).Error; err == nil && next.NoteID != 0 {
+		response["next"] = next.EntryDate.Format(journalDateFormat)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetJournalCalendar returns the dates of a month (?month=YYYY-MM, default: the current month
+// in the user's time zone) that have a journal entry.
+func GetJournalCalendar(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	user, err := FindUserByID(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	month := c.DefaultQuery("month", time.Now().In(userLocation(user)).Format(journalMonthFormat))
+	start, err := time.Parse(journalMonthFormat, month)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Month must be YYYY-MM"})
+		return
+	}
+
+	var entries []models.JournalEntry
+	if err := config.DB.Where("user_id = ? AND entry_date >= ? AND entry_date < ?", user.ID, start, start.AddDate(0, 1, 0)).
+		Order("entry_date").
+		Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch journal entries"})
+		return
+	}
+
+	dates := make([]gin.H, 0, len(entries))
+	for _, entry := range entries {
+		dates = append(dates, gin.H{"date": entry.EntryDate.Format(journalDateFormat), "note_id": entry.NoteID})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"month": month, "data": dates})
+}
+
+// UpdateUserSettings updates the time zone and journal settings of the user
+func UpdateUserSettings(c *gin.Context) {
+	var input struct {
+		Timezone          *string `json:"timezone"`
+		JournalNotebookID *uint   `json:"journal_notebook_id"`
+		JournalTemplateID *uint   `json:"journal_template_id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	user, err := FindUserByID(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	if input.Timezone != nil {
+		if _, err := time.LoadLocation(*input.Timezone); err != nil || *input.Timezone == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown time zone"})
+			return
+		}
+		user.Timezone = *input.Timezone
+	}
+	if input.JournalNotebookID != nil {
+		if !notebookInWorkspace(config.DB, personalWorkspace(user.ID), *input.JournalNotebookID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Notebook not found or access denied"})
+			return
+		}
+		if notebookIsEncrypted(config.DB, *input.JournalNotebookID) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": errEncryptedNotebook})
+			return
+		}
+		user.JournalNotebookID = input.JournalNotebookID
+	}
+	if input.JournalTemplateID != nil {
+		var template models.Template
+		if err := config.DB.Where("id = ? AND user_id = ?", *input.JournalTemplateID, user.ID).First(&template).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Template not found or access denied"})
+			return
+		}
+		user.JournalTemplateID = input.JournalTemplateID
+	}
+
+	if err := config.DB.Model(user).Select("timezone", "journal_notebook_id", "journal_template_id").Updates(user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"timezone":            user.Timezone,
+		"journal_notebook_id": user.JournalNotebookID,
+		"journal_template_id": user.JournalTemplateID,
+	}})
+}
+
+// Private helper functions.
+
+// userLocation returns the configured time zone of the user, falling back to UTC.
+func userLocation(user *models.User) *time.Location {
+	if location, err := time.LoadLocation(user.Timezone); err == nil && user.Timezone != "" {
+		return location
+	}
+	return time.UTC
+}
+
+// createDailyNote creates the journal note of the date, in the user's journal notebook
+// (created on first use in their personal workspace) and rendered from their journal
+// template if they have one. Concurrent first requests for the same day wait for each other, and
+// all but the first return the note it created, reporting false. It returns errQuotaExceeded once
+// it wrote the response refusing a note over the quota of the user.
+func createDailyNote(c *gin.Context, user *models.User, date time.Time, location *time.Location) (models.Note, bool, error) {
+	var note models.Note
+	created := false
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", fmt.Sprintf("journal:%d", user.ID)).Error; err != nil {
+			return err
+		}
+		// The entry, or the journal notebook, may have been created while waiting for the lock
+		var entry models.JournalEntry
+		if err := tx.Where("user_id = ? AND entry_date = ?", user.ID, date).Limit(1).Find(&entry).Error; err != nil {
+			return err
+		}
+		if entry.NoteID != 0 {
+			return tx.First(&note, entry.NoteID).Error
+		}
+		if err := tx.Select("id", "journal_notebook_id", "journal_template_id").Where("id = ?", user.ID).Take(user).Error; err != nil {
+			return err
+		}
+
+		if !enforceQuota(c, tx, user.ID, quotaNotes, 1) {
+			return errQuotaExceeded
+		}
+
+		workspaceID := personalWorkspace(user.ID)
+		var notebook models.Notebook
+		if user.JournalNotebookID == nil || tx.Scopes(workspaceScope(workspaceID)).Where("id = ? AND encrypted = ?", *user.JournalNotebookID, false).First(&notebook).Error != nil {
+			notebook = models.Notebook{Name: journalNotebookName, UserID: user.ID, WorkspaceID: workspaceID}
+			if err := tx.Create(&notebook).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(user).Update("journal_notebook_id", notebook.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		title, content := defaultJournalTitle, ""
+		if user.JournalTemplateID != nil {
+			var template models.Template
+			if err := tx.Where("id = ? AND user_id = ?", *user.JournalTemplateID, user.ID).First(&template).Error; err == nil {
+				title, content = template.Title, template.Content
+			}
+		}
+
+		// Placeholders render the entry's date, at the current time of day in the user's time zone
+		now := time.Now().In(location)
+		values := templateValues{
+			Now:      time.Date(date.Year(), date.Month(), date.Day(), now.Hour(), now.Minute(), 0, 0, location),
+			User:     user.Username,
+			Notebook: notebook.Name,
+		}
+		renderedTitle, _ := renderTemplate(title, values)
+		renderedContent, _ := renderTemplate(content, values)
+
+		if renderedTitle = strings.TrimSpace(renderedTitle); renderedTitle == "" {
+			renderedTitle = date.Format(journalDateFormat)
+		}
+
+		note = models.Note{
+			Title:      renderedTitle,
+			Content:    renderedContent,
+			NotebookID: uint(notebook.ID),
+			UserID:     user.ID,
+		}
+		if err := tx.Create(&note).Error; err != nil {
+			return err
+		}
+
+		created = true
+		return tx.Create(&models.JournalEntry{UserID: user.ID, EntryDate: date, NoteID: uint(note.ID)}).Error
+	})
+
+	return note, created, err
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/models"
+	"noteapp-framework-backend/notifications"
+)
+
+// mentionPattern matches @username when it is not part of a word (e.g. an e-mail address).
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([A-Za-z0-9_.\-]+)`)
+
+// parseMentions returns the unique usernames mentioned in the content, in order of appearance.
+func parseMentions(content string) []string {
+	var usernames []string
+	seen := make(map[string]bool)
+
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		username := strings.TrimRight(match[1], ".-")
+		if username == "" || seen[username] {
+			continue
+		}
+		seen[username] = true
+		usernames = append(usernames, username)
+	}
+
+	return usernames
+}
+
+// notifyMentions notifies every user mentioned in the note who was not already
+// mentioned in previousContent. The author never gets notified about their own mentions, and
+// users outside the workspace of the note never learn about it.
+// Mentions in end-to-end encrypted or locked notes cannot be read, so they notify nobody.
+func notifyMentions(note models.Note, previousContent string, actorID uint) {
+	if note.KeyVersion > 0 || note.Locked {
+		return
+	}
+
+	alreadyMentioned := make(map[string]bool)
+	for _, username := range parseMentions(previousContent) {
+		alreadyMentioned[username] = true
+	}
+
+	var newMentions []string
+	for _, username := range parseMentions(note.Content) {
+		if !alreadyMentioned[username] {
+			newMentions = append(newMentions, username)
+		}
+	}
+	if len(newMentions) == 0 {
+		return
+	}
+
+	var actor models.User
+	if err := config.DB.First(&actor, "id = ?", actorID).Error; err != nil {
+		log.Printf("Failed to load mention author %d: %v", actorID, err)
+		return
+	}
+
+	var users []models.User
+	members := config.DB.Model(&models.WorkspaceMember{}).Select("user_id").Where("workspace_id = ?", notebookWorkspace(note.NotebookID))
+	if err := config.DB.Where("username IN ? AND id IN (?)", newMentions, members).Find(&users).Error; err != nil {
+		log.Printf("Failed to resolve mentions for note %d: %v", note.ID, err)
+		return
+	}
+
+	noteID := uint(note.ID)
+	for _, user := range users {
+		if user.ID == actorID {
+			continue
+		}
+
+		err := notifications.Notify(models.Notification{
+			UserID:  user.ID,
+			ActorID: &actorID,
+			Type:    models.NotificationMention,
+			NoteID:  &noteID,
+			Message: fmt.Sprintf("%s mentioned you in \"%s\"", actor.Username, note.Title),
+		})
+		if err != nil {
+			log.Printf("Failed to notify user %d about mention: %v", user.ID, err)
+		}
+	}
+}
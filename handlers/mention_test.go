@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMentions(t *testing.T) {
+	content := "@alice please sync with @bob.\nMail bob@example.com, cc @alice and @carol_1"
+
+	assert.Equal(t, []string{"alice", "bob", "carol_1"}, parseMentions(content))
+	assert.Empty(t, parseMentions("no mentions here"))
+}
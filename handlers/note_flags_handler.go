@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/models"
+)
+
+// UpdateNoteFlags sets the pinned, favorite and archived flags of a note. Omitted flags are left unchanged.
+func UpdateNoteFlags(c *gin.Context) {
+	_, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var input struct {
+		Pinned   *bool `json:"pinned"`
+		Favorite *bool `json:"favorite"`
+		Archived *bool `json:"archived"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	id := c.Param("id")
+	var note models.Note
+	if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", id).First(&note).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found or access denied"})
+		return
+	}
+
+	if input.Pinned == nil && input.Favorite == nil && input.Archived == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one of pinned, favorite or archived is required"})
+		return
+	}
+
+	before := gin.H{"pinned": note.Pinned, "favorite": note.Favorite, "archived": note.Archived}
+	if input.Pinned != nil {
+		note.Pinned = *input.Pinned
+	}
+	if input.Favorite != nil {
+		note.Favorite = *input.Favorite
+	}
+	if input.Archived != nil {
+		note.Archived = *input.Archived
+	}
+
+	// Flags are not edits of the note, so updated_at (and the recent list) are left alone
+	if err := config.DB.Model(&note).UpdateColumns(map[string]interface{}{
+		"pinned":   note.Pinned,
+		"favorite": note.Favorite,
+		"archived": note.Archived,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update note"})
+		return
+	}
+
+	recordActivity(c, models.Activity{
+		Action:     models.ActivityNoteUpdate,
+		TargetType: "note",
+		TargetID:   uintPtr(uint(note.ID)),
+		NotebookID: uintPtr(note.NotebookID),
+	}, before, gin.H{"pinned": note.Pinned, "favorite": note.Favorite, "archived": note.Archived})
+
+	c.JSON(http.StatusOK, gin.H{"data": note})
+}
+
+// ArchiveNotebook archives or restores a notebook
+func ArchiveNotebook(c *gin.Context) {
+	_, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var input struct {
+		Archived *bool `json:"archived" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	id := c.Param("id")
+	var notebook models.Notebook
+	if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", id).First(&notebook).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notebook not found or access denied"})
+		return
+	}
+
+	before := gin.H{"archived": notebook.Archived}
+	notebook.Archived = *input.Archived
+	if err := config.DB.Model(&notebook).Update("archived", notebook.Archived).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notebook"})
+		return
+	}
+
+	recordActivity(c, models.Activity{
+		Action:     models.ActivityNotebookUpdate,
+		TargetType: "notebook",
+		TargetID:   uintPtr(uint(notebook.ID)),
+		NotebookID: uintPtr(uint(notebook.ID)),
+	}, before, gin.H{"archived": notebook.Archived})
+
+	c.JSON(http.StatusOK, gin.H{"data": notebook})
+}
+
+// GetFavorites retrieves the favorite notes of the active workspace across all notebooks.
+// Notes of archived notebooks are left out, like the notebooks themselves.
+func GetFavorites(c *gin.Context) {
+	_, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var notes []models.Note
+	if err := config.DB.Scopes(inWorkspace(c), inActiveNotebook).Where("favorite = ? AND archived = ?", true, false).
+		Order("pinned DESC, updated_at DESC, id DESC").
+		Find(&notes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch favorites"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": notes})
+}
+
+// Private helper functions.
+
+// showArchived reports whether a listing was asked for archived items (?archived=true)
+// instead of the default, which hides them.
+func showArchived(c *gin.Context) bool {
+	return c.Query("archived") == "true"
+}
+
+// inActiveNotebook narrows notes to the ones whose notebook is not archived.
+func inActiveNotebook(db *gorm.DB) *gorm.DB {
+	return db.Where("notes.notebook_id NOT IN (SELECT id FROM notebooks WHERE archived = ?)", true)
+}
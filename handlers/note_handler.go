@@ -1,24 +1,34 @@
 package handlers
 
 import (
-	"fmt"
+	"errors"
 	"math"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-resty/resty/v2"
+	"gorm.io/gorm"
 
 	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/encryption"
 	"noteapp-framework-backend/models"
 )
 
+// noteSortColumns maps the ?sort= keys of note listings to their column.
+var noteSortColumns = map[string]string{
+	"created": "created_at",
+	"updated": "updated_at",
+	"title":   "title",
+}
+
 // CreateNote creates a new note
 func CreateNote(c *gin.Context) {
 	var input struct {
 		Title      string `json:"title" binding:"required"`
 		Content    string `json:"content" binding:"required"`
 		NotebookID uint   `json:"notebook_id" binding:"required"`
+		KeyVersion int    `json:"key_version"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -31,6 +41,7 @@ func CreateNote(c *gin.Context) {
 		Title:      input.Title,
 		Content:    input.Content,
 		NotebookID: input.NotebookID,
+		KeyVersion: input.KeyVersion,
 	}
 
 	// Set user_id in note.
@@ -51,17 +62,36 @@ func CreateNote(c *gin.Context) {
 	}
 	note.UserID = uint(userIDUint)
 
-	if err := config.DB.Create(&note).Error; err != nil {
+	if !notebookInWorkspace(config.DB, c.GetUint("workspace_id"), note.NotebookID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notebook not found or access denied"})
+		return
+	}
+	if !checkNoteEncryption(c, note.NotebookID, userID, note.KeyVersion, true) {
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if !enforceQuota(c, tx, userID, quotaNotes, 1) {
+			return errQuotaExceeded
+		}
+		return tx.Create(&note).Error
+	})
+	if errors.Is(err, errQuotaExceeded) {
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create note"})
 		return
 	}
 
+	afterNoteCreated(c, note)
+
 	c.JSON(http.StatusCreated, gin.H{"data": note})
 }
 
-// GetNotes retrieves all notes
+// GetNotes retrieves all notes of a notebook, pinned notes first. Archived notes are only listed with ?archived=true
 func GetNotes(c *gin.Context) {
-	userID, exists := c.Get("user_id")
+	_, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
 		return
@@ -70,7 +100,9 @@ func GetNotes(c *gin.Context) {
 	var notes []models.Note
 	notebookID := c.Param("notebookid")
 
-	if err := config.DB.Where("user_id = ? AND notebook_id = ?", userID, notebookID).Find(&notes).Error; err != nil {
+	if err := config.DB.Scopes(inWorkspace(c)).Where("notebook_id = ? AND archived = ?", notebookID, showArchived(c)).
+		Order("pinned DESC, id").
+		Find(&notes).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notes"})
 		return
 	}
@@ -83,7 +115,7 @@ func GetNotes(c *gin.Context) {
 // GetNote retrieves a single note by ID
 func GetNote(c *gin.Context) {
 	// Retrieve user ID from the context
-	userID, exists := c.Get("user_id")
+	_, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
 		return
@@ -94,12 +126,15 @@ func GetNote(c *gin.Context) {
 
 	var note models.Note
 
-	// Fetch the note and ensure it belongs to the authenticated user
-	if err := config.DB.Where("id = ? AND user_id = ? AND notebook_id = ?", noteID, userID, notebookID).First(&note).Error; err != nil {
+	// Fetch the note and ensure it belongs to the active workspace
+	if err := config.DB.Scopes(inWorkspace(c)).Where("id = ? AND notebook_id = ?", noteID, notebookID).First(&note).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"}) // TODO: Add "or access denied" to msg
 		return
 	}
 
+	// Locked notes only come with their content while unlocked in the session
+	decryptUnlockedNote(c, &note)
+
 	c.JSON(http.StatusOK, gin.H{"data": note})
 }
 
@@ -115,23 +150,79 @@ func UpdateNote(c *gin.Context) {
 	id := c.Param("id")
 	var note models.Note
 
-	// Fetch the note and ensure it belongs to the authenticated user
-	if err := config.DB.Where("id = ? AND user_id = ?", id, userID).First(&note).Error; err != nil {
+	// Fetch the note and ensure it belongs to the active workspace
+	if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", id).First(&note).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"}) // TODO: Add "or access denied" to msg
 		return
 	}
 
-	// Bind the updated data
+	// Locked notes can only be edited while unlocked in the session
+	storedContent := note.Content
+	if !decryptUnlockedNote(c, &note) {
+		c.JSON(http.StatusLocked, gin.H{"error": "Note is locked"})
+		return
+	}
+
+	previousContent, previousTitle := note.Content, note.Title
+	original := note
+
+	// Bind the updated data (but keep the original id, user_id, created_at and lock)
+	noteID, ownerID, notebookID, createdAt, locked := note.ID, note.UserID, note.NotebookID, note.CreatedAt, note.Locked
 	if err := c.ShouldBindJSON(&note); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	note.ID, note.UserID, note.CreatedAt, note.Locked = noteID, ownerID, createdAt, locked
+
+	// Changing the notebook is a move, so the destination must belong to the workspace as well
+	if note.NotebookID != notebookID {
+		var destination models.Notebook
+		if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", note.NotebookID).First(&destination).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Destination notebook not found or access denied"})
+			return
+		}
+		// Encrypted notes are bound to the key of their notebook
+		if destination.Encrypted || notebookIsEncrypted(config.DB, notebookID) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": errEncryptedNotebook})
+			return
+		}
+	}
+	if !checkNoteEncryption(c, note.NotebookID, userID, note.KeyVersion, note.Title != previousTitle || note.Content != previousContent) {
+		return
+	}
 
-	// Save the updated note
+	// Save the updated note, with the content of a locked note encrypted again
+	content := note.Content
+	if note.Locked {
+		sealed, unlocked, err := relockContent(c, note.ID, storedContent, content)
+		if !unlocked {
+			c.JSON(http.StatusLocked, gin.H{"error": "Note is locked"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update note"})
+			return
+		}
+		note.Content = sealed
+	}
 	if err := config.DB.Save(&note).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update note"})
 		return
 	}
+	note.Content = content
+
+	userIDUint, err := strconv.ParseUint(userID.(string), 10, 32)
+	if err == nil {
+		notifyMentions(note, previousContent, uint(userIDUint))
+	}
+	afterNoteSaved(note, previousTitle)
+
+	recordActivity(c, models.Activity{
+		Action:     models.ActivityNoteUpdate,
+		TargetType: "note",
+		TargetID:   uintPtr(uint(note.ID)),
+		NotebookID: uintPtr(note.NotebookID),
+	}, noteSummary(original), noteUpdateSummary(original, note))
 
 	c.JSON(http.StatusOK, gin.H{"data": note})
 }
@@ -148,70 +239,154 @@ func DeleteNote(c *gin.Context) {
 	id := c.Param("id")
 	var note models.Note
 
-	// Fetch the note and ensure it belongs to the authenticated user
-	if err := config.DB.Where("id = ? AND user_id = ?", id, userID).First(&note).Error; err != nil {
+	// Fetch the note and ensure it belongs to the active workspace, and that the user holds the
+	// key of an encrypted notebook
+	if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", id).First(&note).Error; err != nil || !holdsNoteKey(config.DB, note, userID) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"}) // TODO: Add "or access denied" to msg
 		return
 	}
 
+	hashes := attachmentHashes(config.DB, []int{note.ID})
+
 	// Delete the note
 	if err := config.DB.Delete(&note).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete note"})
 		return
 	}
+	pruneAttachmentBlobs(hashes)
+
+	recordActivity(c, models.Activity{
+		Action:     models.ActivityNoteDelete,
+		TargetType: "note",
+		TargetID:   uintPtr(uint(note.ID)),
+		NotebookID: uintPtr(note.NotebookID),
+	}, noteSummary(note), nil)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Note deleted successfully"})
 }
 
+// GetRecentNotes retrieves the most recently edited notes across all notebooks, leaving out archived notebooks
+func GetRecentNotes(c *gin.Context) {
+	_, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	limitInt, err := parsePageSize(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var notes []models.Note
+	if err := config.DB.Scopes(inWorkspace(c), inActiveNotebook).Where("archived = ?", false).Order("updated_at DESC, id DESC").Limit(limitInt).Find(&notes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": notes})
+}
+
+// GetNotesWithPagination retrieves the notes of a notebook page by page, pinned notes first.
+// Archived notes are only listed with ?archived=true.
+// Pages are requested with ?cursor= (keyset pagination, preferred) or ?page= (offset pagination),
+// sorted with ?sort=created|updated|title (prefix with "-" for descending order)
+// and filtered with the created_*/updated_* date ranges and ?title_prefix=.
 func GetNotesWithPagination(c *gin.Context) {
 	// Retrieve user ID from the context
-	userID, exists := c.Get("user_id")
+	_, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
 		return
 	}
 
-	// Get page and limit from query parameters, set defaults if not provided
 	notebookID := c.Param("notebookid")
-	page := c.DefaultQuery("page", "1")
-	limit := c.DefaultQuery("limit", "10")
-
-	fmt.Println("notebookid: ", notebookID, " // page: ", page, " // limit: ", limit)
 
-	// Convert string to integer
-	pageInt, _ := strconv.Atoi(page)
-	limitInt, _ := strconv.Atoi(limit)
+	// Titles of encrypted notes are ciphertext, so they cannot be filtered by prefix
+	if c.Query("title_prefix") != "" {
+		if id, err := strconv.ParseUint(notebookID, 10, 32); err == nil && notebookIsEncrypted(config.DB, uint(id)) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": errEncryptedNotebook})
+			return
+		}
+	}
 
-	// Calculate offset
-	offset := (pageInt - 1) * limitInt
+	limitInt, err := parsePageSize(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	var notes []models.Note
-	var total int64
+	sort, err := parseListSort(c.DefaultQuery("sort", "created"), noteSortColumns)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	sort.PinnedFirst = true
 
-	// Get total count of notes
-	config.DB.Model(&models.Note{}).
-		Where("notebook_id = ? AND user_id = ?", notebookID, userID).
-		Count(&total)
+	// The database cannot filter or sort titles encrypted at rest
+	if encryption.Enabled() && (c.Query("title_prefix") != "" || sort.Key == "title") {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Filtering and sorting by title are not available while notes are encrypted at rest"})
+		return
+	}
 
-	// Get notes with pagination, without loading relationships
-	result := config.DB.Model(&models.Note{}).
-		Where("notebook_id = ? AND user_id = ?", notebookID, userID).
-		Limit(limitInt).
-		Offset(offset).
-		Find(&notes)
+	query, err := applyListFilters(c, config.DB.Model(&models.Note{}).Scopes(inWorkspace(c)).Where("notebook_id = ? AND archived = ?", notebookID, showArchived(c)), "title_prefix", "title")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notes"})
+	// Get total count of notes matching the filters
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notes"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"data":       notes,
+	response := gin.H{
 		"total":      total,
-		"page":       pageInt,
 		"limit":      limitInt,
+		"sort":       c.DefaultQuery("sort", "created"),
 		"totalPages": int(math.Ceil(float64(total) / float64(limitInt))),
-	})
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		query, err = applyListCursor(query, sort, cursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else if page := c.Query("page"); page != "" {
+		pageInt, err := strconv.Atoi(page)
+		if err != nil || pageInt < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page"})
+			return
+		}
+		query = query.Offset((pageInt - 1) * limitInt)
+		response["page"] = pageInt
+	}
+
+	// Fetch one extra note to know whether there is a next page
+	var notes []models.Note
+	if err := query.Order(sort.order()).Limit(limitInt + 1).Find(&notes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notes"})
+		return
+	}
+
+	var nextCursor *string
+	if len(notes) > limitInt {
+		notes = notes[:limitInt]
+		cursor, err := nextListCursor(config.DB.Scopes(inWorkspace(c)), &models.Note{}, sort, notes[len(notes)-1].ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build cursor"})
+			return
+		}
+		nextCursor = &cursor
+	}
+
+	response["data"] = notes
+	response["next_cursor"] = nextCursor
+	c.JSON(http.StatusOK, response)
 }
 
 // ExportNote forwards the export request to the export-service
@@ -225,14 +400,33 @@ func ExportNote(c *gin.Context) {
 	}
 
 	var note models.Note
-	if err := config.DB.Where("id = ? AND user_id = ?", noteID, userID).First(&note).Error; err != nil {
+	if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", noteID).First(&note).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found or access denied"})
 		return
 	}
+	if note.KeyVersion > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": errEncryptedNotebook})
+		return
+	}
+	if !decryptUnlockedNote(c, &note) {
+		c.JSON(http.StatusLocked, gin.H{"error": "Note is locked"})
+		return
+	}
 
 	requestBody := map[string]interface{}{
 		"title":   note.Title,
 		"content": note.Content,
+		"images":  noteExportImages(c, note.Content),
+	}
+
+	activity := models.Activity{
+		Action:     models.ActivityNoteExport,
+		TargetType: "note",
+		TargetID:   uintPtr(uint(note.ID)),
+		NotebookID: uintPtr(note.NotebookID),
+	}
+	if !reserveExport(c, userID, &activity) {
+		return
 	}
 
 	// Call the export-service
@@ -243,10 +437,32 @@ func ExportNote(c *gin.Context) {
 		Post("http://localhost:8081/export/note")
 
 	if err != nil {
+		releaseExport(activity)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export note"})
 		return
 	}
 
+	// Only successful exports count towards the daily export quota
+	if !resp.IsSuccess() {
+		releaseExport(activity)
+	}
+
 	// Forward the response from the export-service
 	c.Data(resp.StatusCode(), resp.Header().Get("Content-Type"), resp.Body())
 }
+
+// Private helper functions.
+
+// afterNoteCreated runs the side effects of creating a note: mention notifications,
+// link and task parsing and the activity log entry.
+func afterNoteCreated(c *gin.Context, note models.Note) {
+	notifyMentions(note, "", note.UserID)
+	afterNoteSaved(note, "")
+
+	recordActivity(c, models.Activity{
+		Action:     models.ActivityNoteCreate,
+		TargetType: "note",
+		TargetID:   uintPtr(uint(note.ID)),
+		NotebookID: uintPtr(note.NotebookID),
+	}, nil, noteSummary(note))
+}
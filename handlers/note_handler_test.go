@@ -51,12 +51,15 @@ func initNoteTestDB() {
 
 	// Insert a test user
 	config.DB.Exec("INSERT INTO users (id, username, password) VALUES (1, 'Test', 'password')")
+	config.DB.Exec("INSERT INTO workspaces (id, name, personal_user_id) VALUES (1, 'Personal', 1)")
+	config.DB.Exec("INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (1, 1, 'admin')")
 }
 
 func mockNoteAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Simulate an authenticated user by setting a user ID in the context
 		c.Set("user_id", "1")
+		c.Set("workspace_id", uint(1))
 		c.Next()
 	}
 }
@@ -66,7 +69,7 @@ func TestCreateNote(t *testing.T) {
 	router := setupNoteTestRouter()
 
 	// Insert a notebook manually for the test
-	notebook := models.Notebook{ID: 1, Name: "Test Notebook", UserID: 1}
+	notebook := models.Notebook{ID: 1, Name: "Test Notebook", UserID: 1, WorkspaceID: 1}
 	config.DB.Create(&notebook)
 
 	note := models.Note{Title: "Test Note", Content: "Test Content", NotebookID: 1}
@@ -97,7 +100,7 @@ func TestGetNotes(t *testing.T) {
 	router := setupNoteTestRouter()
 
 	// Insert a notebook manually for the test
-	notebook := models.Notebook{ID: 1, Name: "Test Notebook", UserID: 1}
+	notebook := models.Notebook{ID: 1, Name: "Test Notebook", UserID: 1, WorkspaceID: 1}
 	config.DB.Create(&notebook)
 
 	// Insert notes associated with the notebook
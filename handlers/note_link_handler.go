@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/encryption"
+	"noteapp-framework-backend/models"
+)
+
+// wikiLinkPattern matches [[Target]] and [[Target|alias]]. A target of the form #12 links by note ID.
+var wikiLinkPattern = regexp.MustCompile(`\[\[([^\[\]|]+)(\|[^\[\]]*)?\]\]`)
+
+type linkedNote struct {
+	ID         int    `json:"id"`
+	Title      string `json:"title" gorm:"serializer:encrypted;encrypted:notes.title"`
+	NotebookID uint   `json:"notebook_id"`
+}
+
+// GetNoteLinks retrieves the outgoing links of a note, including dangling ones
+func GetNoteLinks(c *gin.Context) {
+	_, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var note models.Note
+	if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", noteIDParam(c)).First(&note).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found or access denied"})
+		return
+	}
+
+	var links []models.NoteLink
+	if err := config.DB.Where("source_note_id = ?", note.ID).Order("id").Find(&links).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch links"})
+		return
+	}
+
+	var targetIDs []uint
+	for _, link := range links {
+		if link.TargetNoteID != nil {
+			targetIDs = append(targetIDs, *link.TargetNoteID)
+		}
+	}
+	targets := make(map[uint]linkedNote)
+	if len(targetIDs) > 0 {
+		var notes []linkedNote
+		if err := config.DB.Model(&models.Note{}).Scopes(inWorkspace(c)).Where("id IN ?", targetIDs).Find(&notes).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch linked notes"})
+			return
+		}
+		for _, target := range notes {
+			targets[uint(target.ID)] = target
+		}
+	}
+
+	data := make([]gin.H, 0, len(links))
+	for _, link := range links {
+		entry := gin.H{"target_title": link.TargetTitle, "dangling": true, "target": nil}
+		if link.TargetNoteID != nil {
+			if target, ok := targets[*link.TargetNoteID]; ok {
+				entry["dangling"] = false
+				entry["target"] = target
+			}
+		}
+		data = append(data, entry)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// GetNoteBacklinks retrieves the notes linking to a note
+func GetNoteBacklinks(c *gin.Context) {
+	_, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var note models.Note
+	if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", noteIDParam(c)).First(&note).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found or access denied"})
+		return
+	}
+
+	var backlinks []linkedNote
+	if err := config.DB.Model(&models.Note{}).
+		Scopes(inWorkspace(c)).
+		Where("id IN (?)",
+			config.DB.Model(&models.NoteLink{}).Select("source_note_id").Where("target_note_id = ?", note.ID)).
+		Order("title").
+		Find(&backlinks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch backlinks"})
+		return
+	}
+	// Encrypted titles cannot be sorted by the database
+	if encryption.Enabled() {
+		sort.SliceStable(backlinks, func(i, j int) bool { return backlinks[i].Title < backlinks[j].Title })
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": backlinks})
+}
+
+// GetDanglingLinks retrieves every link in the active workspace that does not resolve to a note
+func GetDanglingLinks(c *gin.Context) {
+	_, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var dangling []struct {
+		SourceNoteID uint   `json:"source_note_id"`
+		SourceTitle  string `json:"source_title" gorm:"serializer:encrypted;encrypted:notes.title"`
+		TargetTitle  string `json:"target_title" gorm:"serializer:encrypted;encrypted:note_links.target_title"`
+	}
+	if err := config.DB.Model(&models.NoteLink{}).
+		Select("note_links.source_note_id, notes.title AS source_title, note_links.target_title").
+		Joins("JOIN notes ON notes.id = note_links.source_note_id").
+		Scopes(inWorkspace(c)).
+		Where("note_links.target_note_id IS NULL").
+		Order("notes.title, note_links.id").
+		Scan(&dangling).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch dangling links"})
+		return
+	}
+	if encryption.Enabled() {
+		sort.SliceStable(dangling, func(i, j int) bool { return dangling[i].SourceTitle < dangling[j].SourceTitle })
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": dangling})
+}
+
+// Private helper functions.
+
+// noteIDParam returns the note ID path parameter. GET routes below /notes/ have to share the
+// :notebookid wildcard of GET /notes/:notebookid, so on those routes the note ID is found there.
+func noteIDParam(c *gin.Context) string {
+	if id := c.Param("id"); id != "" {
+		return id
+	}
+	return c.Param("notebookid")
+}
+
+// parseWikiLinks returns the unique link targets in the content, in order of appearance.
+func parseWikiLinks(content string) []string {
+	var targets []string
+	seen := make(map[string]bool)
+
+	for _, match := range wikiLinkPattern.FindAllStringSubmatch(content, -1) {
+		target := strings.TrimSpace(match[1])
+		if target == "" || seen[target] {
+			continue
+		}
+		seen[target] = true
+		targets = append(targets, target)
+	}
+
+	return targets
+}
+
+// updateNoteLinks keeps the note_links table in sync after a note was saved: it re-parses the
+// note's own links, resolves dangling links of other notes that point to its title and, when
+// the note was renamed, rewrites the link text in the notes linking to it.
+func updateNoteLinks(note models.Note, previousTitle string) {
+	if err := syncNoteLinks(note); err != nil {
+		log.Printf("Failed to sync links of note %d: %v", note.ID, err)
+	}
+
+	if previousTitle != "" && previousTitle != note.Title {
+		if err := renameNoteLinks(note, previousTitle); err != nil {
+			log.Printf("Failed to rename links to note %d: %v", note.ID, err)
+		}
+	}
+
+	var dangling []models.NoteLink
+	if err := config.DB.Scopes(workspaceScope(notebookWorkspace(note.NotebookID))).
+		Scopes(targetTitleIs(note.Title)).
+		Where("target_note_id IS NULL").
+		Find(&dangling).Error; err != nil {
+		log.Printf("Failed to resolve dangling links to note %d: %v", note.ID, err)
+		return
+	}
+	var ids []int
+	for _, link := range dangling {
+		if link.TargetTitle == note.Title {
+			ids = append(ids, link.ID)
+		}
+	}
+	if len(ids) > 0 {
+		if err := config.DB.Model(&models.NoteLink{}).Where("id IN ?", ids).Update("target_note_id", note.ID).Error; err != nil {
+			log.Printf("Failed to resolve dangling links to note %d: %v", note.ID, err)
+		}
+	}
+}
+
+// targetTitleIs narrows note links to the ones pointing to the title. Encrypted titles cannot be
+// compared by the database, so callers compare TargetTitle again after loading the links.
+func targetTitleIs(title string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if encryption.Enabled() {
+			return db
+		}
+		return db.Where("target_title = ?", title)
+	}
+}
+
+// syncNoteLinks replaces the stored outgoing links of the note with the ones in its content.
+func syncNoteLinks(note models.Note) error {
+	if err := config.DB.Where("source_note_id = ?", note.ID).Delete(&models.NoteLink{}).Error; err != nil {
+		return err
+	}
+
+	workspaceID := notebookWorkspace(note.NotebookID)
+	for _, target := range parseWikiLinks(note.Content) {
+		link := models.NoteLink{UserID: note.UserID, SourceNoteID: uint(note.ID), TargetTitle: target}
+		if targetID, ok := resolveWikiLink(workspaceID, target); ok {
+			link.TargetNoteID = &targetID
+		}
+		if err := config.DB.Create(&link).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resolveWikiLink finds the note of the workspace a link target refers to, by #id or by title.
+func resolveWikiLink(workspaceID uint, target string) (uint, bool) {
+	var note models.Note
+	query := config.DB.Select("id").Scopes(workspaceScope(workspaceID))
+
+	if id, err := strconv.Atoi(strings.TrimPrefix(target, "#")); err == nil && strings.HasPrefix(target, "#") {
+		query = query.Where("id = ?", id)
+	} else if encryption.Enabled() {
+		// Encrypted titles cannot be compared by the database, so the titles are compared here
+		var notes []models.Note
+		if err := config.DB.Select("id", "title").Scopes(workspaceScope(workspaceID)).Order("id").Find(&notes).Error; err != nil {
+			return 0, false
+		}
+		for _, candidate := range notes {
+			if candidate.Title == target {
+				return uint(candidate.ID), true
+			}
+		}
+		return 0, false
+	} else {
+		query = query.Where("title = ?", target)
+	}
+
+	if err := query.Order("id").Limit(1).Find(&note).Error; err != nil || note.ID == 0 {
+		return 0, false
+	}
+	return uint(note.ID), true
+}
+
+// renameNoteLinks rewrites [[Old Title]] to [[New Title]] in every note linking to the renamed note.
+func renameNoteLinks(note models.Note, previousTitle string) error {
+	var links []models.NoteLink
+	if err := config.DB.Scopes(targetTitleIs(previousTitle)).Where("target_note_id = ?", note.ID).Find(&links).Error; err != nil {
+		return err
+	}
+
+	for _, link := range links {
+		if link.TargetTitle != previousTitle {
+			continue
+		}
+
+		var source models.Note
+		if err := config.DB.First(&source, link.SourceNoteID).Error; err != nil {
+			return err
+		}
+
+		source.Content = wikiLinkPattern.ReplaceAllStringFunc(source.Content, func(match string) string {
+			parts := wikiLinkPattern.FindStringSubmatch(match)
+			if strings.TrimSpace(parts[1]) != previousTitle {
+				return match
+			}
+			return "[[" + note.Title + parts[2] + "]]"
+		})
+
+		if err := config.DB.Model(&source).Select("content").Updates(&source).Error; err != nil {
+			return err
+		}
+		if err := syncNoteTasks(source); err != nil {
+			return err
+		}
+		link.TargetTitle = note.Title
+		if err := config.DB.Model(&link).Select("target_title").Updates(&link).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWikiLinks(t *testing.T) {
+	content := "See [[Project Plan]] and [[ Budget |the budget]].\nAlso [[#12]], [[Project Plan]] again and [[]]."
+
+	assert.Equal(t, []string{"Project Plan", "Budget", "#12"}, parseWikiLinks(content))
+	assert.Empty(t, parseWikiLinks("no links [here]"))
+}
@@ -0,0 +1,453 @@
+package handlers
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/argon2"
+
+	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/loginguard"
+	"noteapp-framework-backend/models"
+)
+
+// Argon2id parameters of new locks. They are stored with every locked content, so they can
+// be raised later without breaking existing locks.
+const (
+	lockTime    = 1
+	lockMemory  = 64 * 1024 // KiB
+	lockThreads = 4
+	lockSaltLen = 16
+)
+
+var errWrongPassphrase = errors.New("wrong passphrase")
+
+// noteUnlock is a note key held in memory while the note is unlocked in a session.
+type noteUnlock struct {
+	key     []byte
+	expires time.Time
+}
+
+type unlockKey struct {
+	sessionID string
+	noteID    int
+}
+
+// noteUnlocks holds the derived keys of unlocked notes. They are never stored, so a restart,
+// or a request served by another backend, finds the notes locked again.
+var noteUnlocks = struct {
+	sync.Mutex
+	entries map[unlockKey]noteUnlock
+}{entries: make(map[unlockKey]noteUnlock)}
+
+// LockNote protects the content of a note with a passphrase
+func LockNote(c *gin.Context) {
+	var input struct {
+		Passphrase string `json:"passphrase" binding:"required,min=8"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var note models.Note
+	if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", c.Param("id")).First(&note).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found or access denied"})
+		return
+	}
+	if note.KeyVersion > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": errEncryptedNotebook})
+		return
+	}
+	if note.Locked {
+		c.JSON(http.StatusConflict, gin.H{"error": "Note is already locked"})
+		return
+	}
+
+	params := lockedContent{memory: lockMemory, time: lockTime, threads: lockThreads, salt: make([]byte, lockSaltLen)}
+	if _, err := rand.Read(params.salt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lock note"})
+		return
+	}
+
+	if !startKeyDerivation(c) {
+		return
+	}
+	key := params.deriveKey(input.Passphrase)
+	finishKeyDerivation()
+
+	original := note
+	content, err := sealLockedContent(note.Content, key, params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lock note"})
+		return
+	}
+	note.Content, note.Locked = content, true
+	if err := config.DB.Model(&note).Select("content", "locked").Updates(&note).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lock note"})
+		return
+	}
+
+	// Links and tasks are derived from the content, which is not readable anymore
+	afterNoteSaved(note, note.Title)
+	recordActivity(c, models.Activity{
+		Action:     models.ActivityNoteUpdate,
+		TargetType: "note",
+		TargetID:   uintPtr(uint(note.ID)),
+		NotebookID: uintPtr(note.NotebookID),
+	}, noteSummary(original), noteUpdateSummary(original, note))
+
+	c.JSON(http.StatusOK, gin.H{"data": note})
+}
+
+// UnlockNote makes a locked note readable and editable in the current session for the
+// configured unlock window, and returns it with its content
+func UnlockNote(c *gin.Context) {
+	var input struct {
+		Passphrase string `json:"passphrase" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+	sessionID := c.GetString("session_id")
+	if sessionID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Log in again to unlock notes"})
+		return
+	}
+
+	var note models.Note
+	if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", c.Param("id")).First(&note).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found or access denied"})
+		return
+	}
+	if !note.IsLockedContent() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Note is not locked"})
+		return
+	}
+
+	plaintext, key, ok := tryPassphrase(c, note, input.Passphrase)
+	if !ok {
+		return
+	}
+
+	unlock := noteUnlock{key: key, expires: time.Now().Add(config.GetNoteUnlockWindow())}
+	noteUnlocks.Lock()
+	noteUnlocks.entries[unlockKey{sessionID, note.ID}] = unlock
+	noteUnlocks.Unlock()
+
+	note.Content = plaintext
+	c.JSON(http.StatusOK, gin.H{"data": note, "unlocked_until": unlock.expires})
+}
+
+// RemoveNoteLock removes the passphrase of a locked note, storing its content like any other
+func RemoveNoteLock(c *gin.Context) {
+	var input struct {
+		Passphrase string `json:"passphrase" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var note models.Note
+	if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", c.Param("id")).First(&note).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found or access denied"})
+		return
+	}
+	if !note.IsLockedContent() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Note is not locked"})
+		return
+	}
+
+	plaintext, _, ok := tryPassphrase(c, note, input.Passphrase)
+	if !ok {
+		return
+	}
+
+	original := note
+	note.Content, note.Locked = plaintext, false
+	if err := config.DB.Model(&note).Select("content", "locked").Updates(&note).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update note"})
+		return
+	}
+	forgetNoteUnlocks(note.ID)
+
+	afterNoteSaved(note, note.Title)
+	recordActivity(c, models.Activity{
+		Action:     models.ActivityNoteUpdate,
+		TargetType: "note",
+		TargetID:   uintPtr(uint(note.ID)),
+		NotebookID: uintPtr(note.NotebookID),
+	}, noteSummary(original), noteUpdateSummary(original, note))
+
+	c.JSON(http.StatusOK, gin.H{"data": note})
+}
+
+// Private helper functions.
+
+// keyDerivations bounds the passphrase key derivations running at once, as each one takes
+// lockMemory of memory.
+var keyDerivations = sync.OnceValue(func() chan struct{} {
+	return make(chan struct{}, config.GetNoteLockDerivations())
+})
+
+// startKeyDerivation waits until a key derivation may start. It responds and returns false if
+// the request ends first.
+func startKeyDerivation(c *gin.Context) bool {
+	select {
+	case keyDerivations() <- struct{}{}:
+		return true
+	case <-c.Request.Context().Done():
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Request cancelled"})
+		return false
+	}
+}
+
+func finishKeyDerivation() {
+	<-keyDerivations()
+}
+
+// tryPassphrase decrypts the content of a locked note with the passphrase and returns it with
+// the derived key. Attempts are limited per note, see loginguard.NoteUnlocks. It responds and
+// returns false when the attempt is rejected or the passphrase is wrong.
+func tryPassphrase(c *gin.Context, note models.Note, passphrase string) (string, []byte, bool) {
+	ctx := c.Request.Context()
+	attemptKey := loginguard.NoteKey(note.ID)
+	failures, wait, err := loginguard.NoteUnlocks.Reserve(ctx, attemptKey, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check unlock attempts"})
+		return "", nil, false
+	}
+	if wait > 0 {
+		c.Header("Retry-After", retryAfter(wait))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many wrong passphrases"})
+		return "", nil, false
+	}
+
+	if !startKeyDerivation(c) {
+		return "", nil, false
+	}
+	plaintext, key, err := openLockedContent(note.Content, passphrase)
+	finishKeyDerivation()
+	if errors.Is(err, errWrongPassphrase) {
+		wait, err := loginguard.NoteUnlocks.Fail(ctx, attemptKey, failures, time.Now())
+		if err != nil {
+			log.Printf("Failed to record unlock attempt of note %d: %v", note.ID, err)
+		}
+		if wait > 0 {
+			c.Header("Retry-After", retryAfter(wait))
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": "Wrong passphrase"})
+		return "", nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock note"})
+		return "", nil, false
+	}
+
+	if err := loginguard.NoteUnlocks.Succeed(ctx, attemptKey); err != nil {
+		log.Printf("Failed to reset unlock attempts of note %d: %v", note.ID, err)
+	}
+	return plaintext, key, true
+}
+
+// unlockedNoteKey returns the key of a locked note if it is unlocked in the session.
+func unlockedNoteKey(c *gin.Context, noteID int) ([]byte, bool) {
+	sessionID := c.GetString("session_id")
+	if sessionID == "" {
+		return nil, false
+	}
+
+	noteUnlocks.Lock()
+	defer noteUnlocks.Unlock()
+
+	now := time.Now()
+	for key, unlock := range noteUnlocks.entries {
+		if now.After(unlock.expires) {
+			delete(noteUnlocks.entries, key)
+		}
+	}
+	unlock, ok := noteUnlocks.entries[unlockKey{sessionID, noteID}]
+	return unlock.key, ok
+}
+
+// decryptUnlockedNote replaces the content of a locked note by its plaintext if the note is
+// unlocked in the session, and reports whether the content is readable.
+func decryptUnlockedNote(c *gin.Context, note *models.Note) bool {
+	if !note.IsLockedContent() {
+		return true
+	}
+	key, ok := unlockedNoteKey(c, note.ID)
+	if !ok {
+		return false
+	}
+	locked, err := parseLockedContent(note.Content)
+	if err != nil {
+		return false
+	}
+	plaintext, err := locked.open(key)
+	if err != nil {
+		return false
+	}
+	note.Content = plaintext
+	return true
+}
+
+// relockContent encrypts new content of a note unlocked in the session with its key and the
+// parameters of the stored lock. It reports false if the note is not unlocked.
+func relockContent(c *gin.Context, noteID int, stored, content string) (string, bool, error) {
+	key, ok := unlockedNoteKey(c, noteID)
+	if !ok {
+		return "", false, nil
+	}
+	locked, err := parseLockedContent(stored)
+	if err != nil {
+		return "", true, err
+	}
+	sealed, err := sealLockedContent(content, key, locked)
+	return sealed, true, err
+}
+
+// forgetNoteUnlocks locks the note again in every session.
+func forgetNoteUnlocks(noteID int) {
+	noteUnlocks.Lock()
+	defer noteUnlocks.Unlock()
+
+	for key := range noteUnlocks.entries {
+		if key.noteID == noteID {
+			delete(noteUnlocks.entries, key)
+		}
+	}
+}
+
+// forgetSessionUnlocks locks every note unlocked in the session, when it ends.
+func forgetSessionUnlocks(sessionID string) {
+	noteUnlocks.Lock()
+	defer noteUnlocks.Unlock()
+
+	for key := range noteUnlocks.entries {
+		if key.sessionID == sessionID {
+			delete(noteUnlocks.entries, key)
+		}
+	}
+}
+
+// lockedContent is the parsed form of the content of a locked note.
+type lockedContent struct {
+	memory, time uint32
+	threads      uint8
+	salt, sealed []byte
+}
+
+// sealLockedContent encrypts the content with AES-256-GCM and encodes the Argon2id
+// parameters, the salt and the ciphertext in the PHC-like format of LockedContentPrefix.
+func sealLockedContent(content string, key []byte, params lockedContent) (string, error) {
+	aead, err := lockAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(content), nil)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", models.LockedContentPrefix, argon2.Version,
+		params.memory, params.time, params.threads,
+		base64.RawStdEncoding.EncodeToString(params.salt), base64.RawStdEncoding.EncodeToString(sealed)), nil
+}
+
+func parseLockedContent(content string) (lockedContent, error) {
+	var locked lockedContent
+	var version int
+
+	parts := strings.Split(strings.TrimPrefix(content, models.LockedContentPrefix), "$")
+	if len(parts) != 4 {
+		return locked, errors.New("malformed locked content")
+	}
+	if _, err := fmt.Sscanf(parts[0], "v=%d", &version); err != nil || version != argon2.Version {
+		return locked, errors.New("unsupported argon2 version")
+	}
+	if _, err := fmt.Sscanf(parts[1], "m=%d,t=%d,p=%d", &locked.memory, &locked.time, &locked.threads); err != nil {
+		return locked, errors.New("malformed argon2 parameters")
+	}
+
+	var err error
+	if locked.salt, err = base64.RawStdEncoding.DecodeString(parts[2]); err != nil {
+		return locked, errors.New("malformed salt")
+	}
+	if locked.sealed, err = base64.RawStdEncoding.DecodeString(parts[3]); err != nil {
+		return locked, errors.New("malformed ciphertext")
+	}
+	return locked, nil
+}
+
+// deriveKey derives the note key from the passphrase with the parameters of the lock.
+func (l lockedContent) deriveKey(passphrase string) []byte {
+	return argon2.IDKey([]byte(passphrase), l.salt, l.time, l.memory, l.threads, 32)
+}
+
+// open decrypts the content. A wrong key fails authentication with errWrongPassphrase.
+func (l lockedContent) open(key []byte) (string, error) {
+	aead, err := lockAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	if len(l.sealed) < aead.NonceSize() {
+		return "", errors.New("malformed locked content")
+	}
+	plaintext, err := aead.Open(nil, l.sealed[:aead.NonceSize()], l.sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", errWrongPassphrase
+	}
+	return string(plaintext), nil
+}
+
+// openLockedContent decrypts the content of a locked note with its passphrase, and returns
+// the derived key as well.
+func openLockedContent(content, passphrase string) (string, []byte, error) {
+	locked, err := parseLockedContent(content)
+	if err != nil {
+		return "", nil, err
+	}
+	key := locked.deriveKey(passphrase)
+	plaintext, err := locked.open(key)
+	return plaintext, key, err
+}
+
+func lockAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
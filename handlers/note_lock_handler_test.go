@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/loginguard"
+	"noteapp-framework-backend/models"
+)
+
+// testLockParams are cheap Argon2id parameters, so the tests stay fast.
+func testLockParams() lockedContent {
+	return lockedContent{memory: 64, time: 1, threads: 1, salt: []byte("0123456789abcdef")}
+}
+
+func TestLockedContentRoundTrip(t *testing.T) {
+	params := testLockParams()
+	sealed, err := sealLockedContent("my secret", params.deriveKey("correct horse"), params)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(sealed, "$argon2id$v=19$m=64,t=1,p=1$"))
+	assert.NotContains(t, sealed, "my secret")
+
+	plaintext, key, err := openLockedContent(sealed, "correct horse")
+	require.NoError(t, err)
+	assert.Equal(t, "my secret", plaintext)
+	assert.Len(t, key, 32)
+
+	_, _, err = openLockedContent(sealed, "wrong horse")
+	assert.ErrorIs(t, err, errWrongPassphrase)
+
+	_, _, err = openLockedContent("$argon2id$garbage", "correct horse")
+	assert.Error(t, err)
+}
+
+func TestLockedNoteJSON(t *testing.T) {
+	params := testLockParams()
+	sealed, err := sealLockedContent("my secret", params.deriveKey("correct horse"), params)
+	require.NoError(t, err)
+
+	data, err := json.Marshal(models.Note{Title: "Passwords", Content: sealed, Locked: true})
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "argon2id")
+	assert.Contains(t, string(data), `"content":""`)
+
+	// Decrypted content is sent as is
+	data, err = json.Marshal(models.Note{Title: "Passwords", Content: "my secret", Locked: true})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"content":"my secret"`)
+
+	// The activity log never keeps the text of a note, only which fields changed
+	summary := noteUpdateSummary(models.Note{Title: "Passwords", Content: "my secret"}, models.Note{Title: "Passwords", Content: sealed, Locked: true})
+	assert.NotContains(t, summary, "title")
+	assert.NotContains(t, summary, "content")
+	assert.Equal(t, []string{"content", "locked"}, summary["changed"])
+}
+
+func TestDecryptUnlockedNote(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	params := testLockParams()
+	key := params.deriveKey("correct horse")
+	sealed, err := sealLockedContent("my secret", key, params)
+	require.NoError(t, err)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set("session_id", "session-1")
+
+	note := models.Note{ID: 42, Content: sealed, Locked: true}
+	assert.False(t, decryptUnlockedNote(c, &note))
+	assert.Equal(t, sealed, note.Content)
+
+	noteUnlocks.Lock()
+	noteUnlocks.entries[unlockKey{"session-1", 42}] = noteUnlock{key: key, expires: time.Now().Add(time.Minute)}
+	noteUnlocks.Unlock()
+	defer forgetNoteUnlocks(42)
+
+	assert.True(t, decryptUnlockedNote(c, &note))
+	assert.Equal(t, "my secret", note.Content)
+
+	// Other sessions still find the note locked
+	other, _ := gin.CreateTestContext(httptest.NewRecorder())
+	other.Set("session_id", "session-2")
+	note.Content = sealed
+	assert.False(t, decryptUnlockedNote(other, &note))
+
+	// New content is encrypted with the same lock
+	resealed, unlocked, err := relockContent(c, 42, sealed, "new secret")
+	require.NoError(t, err)
+	assert.True(t, unlocked)
+	plaintext, _, err := openLockedContent(resealed, "correct horse")
+	require.NoError(t, err)
+	assert.Equal(t, "new secret", plaintext)
+
+	forgetSessionUnlocks("session-1")
+	assert.False(t, decryptUnlockedNote(c, &note))
+}
+
+func TestTryPassphraseLimitsAttempts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	params := testLockParams()
+	sealed, err := sealLockedContent("my secret", params.deriveKey("correct horse"), params)
+	require.NoError(t, err)
+	note := models.Note{ID: 42, Content: sealed, Locked: true}
+
+	previous := loginguard.NoteUnlocks
+	loginguard.NoteUnlocks = loginguard.NewAttempts(loginguard.NewMemory(), config.LoginLimits{
+		MaxFailures: 2, DelayAfter: 2, Window: time.Hour, Lockout: time.Hour,
+	})
+	defer func() { loginguard.NoteUnlocks = previous }()
+
+	try := func(passphrase string) (*httptest.ResponseRecorder, bool) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("POST", "/notes/42/unlock", nil)
+		_, _, ok := tryPassphrase(c, note, passphrase)
+		return w, ok
+	}
+
+	// A correct passphrase forgets the wrong ones before it
+	w, ok := try("wrong horse")
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	_, ok = try("correct horse")
+	assert.True(t, ok)
+
+	try("wrong horse")
+	try("wrong horse")
+	w, ok = try("correct horse")
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "3600", w.Header().Get("Retry-After"))
+}
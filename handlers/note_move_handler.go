@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/models"
+)
+
+// MoveNote moves a note into another notebook of the user
+func MoveNote(c *gin.Context) {
+	transferSingleNote(c, false)
+}
+
+// CopyNote copies a note into another notebook of the user
+func CopyNote(c *gin.Context) {
+	transferSingleNote(c, true)
+}
+
+// MoveNotes moves several notes into another notebook of the user
+func MoveNotes(c *gin.Context) {
+	transferNoteList(c, false)
+}
+
+// CopyNotes copies several notes into another notebook of the user
+func CopyNotes(c *gin.Context) {
+	transferNoteList(c, true)
+}
+
+// Private helper functions.
+
+func transferSingleNote(c *gin.Context, copyNote bool) {
+	var input struct {
+		NotebookID uint `json:"notebook_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid note ID"})
+		return
+	}
+
+	notes, ok := transferNotes(c, []int{id}, input.NotebookID, copyNote)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": notes[0]})
+}
+
+func transferNoteList(c *gin.Context, copyNote bool) {
+	var input struct {
+		IDs        []int `json:"ids" binding:"required,min=1"`
+		NotebookID uint  `json:"notebook_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	notes, ok := transferNotes(c, input.IDs, input.NotebookID, copyNote)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": notes})
+}
+
+// transferNotes moves or copies the notes into the destination notebook in a single
+// transaction. It writes the error response itself and reports whether it succeeded.
+func transferNotes(c *gin.Context, ids []int, notebookID uint, copyNote bool) ([]models.Note, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return nil, false
+	}
+
+	var destination models.Notebook
+	if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", notebookID).First(&destination).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Destination notebook not found or access denied"})
+		return nil, false
+	}
+	if rejectEncrypted(c, destination) {
+		return nil, false
+	}
+
+	var notes []models.Note
+	if err := config.DB.Scopes(inWorkspace(c)).Where("id IN ?", ids).Find(&notes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notes"})
+		return nil, false
+	}
+	found := make(map[int]models.Note, len(notes))
+	for _, note := range notes {
+		found[note.ID] = note
+	}
+	for _, id := range ids {
+		note, ok := found[id]
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Note not found or access denied", "id": id})
+			return nil, false
+		}
+		if note.KeyVersion > 0 {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": errEncryptedNotebook, "id": id})
+			return nil, false
+		}
+	}
+
+	var result []models.Note
+	var activities []models.Activity
+	var befores []gin.H
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if copyNote && !enforceQuota(c, tx, userID, quotaNotes, int64(len(ids))) {
+			return errQuotaExceeded
+		}
+		for _, id := range ids {
+			note := found[id]
+			before := noteSummary(note)
+
+			activity := models.Activity{
+				Action:     models.ActivityNoteMove,
+				TargetType: "note",
+				NotebookID: uintPtr(notebookID),
+			}
+
+			if copyNote {
+				note.ID = 0
+				note.NotebookID = notebookID
+				note.CreatedAt, note.UpdatedAt = time.Time{}, time.Time{}
+				if err := tx.Create(&note).Error; err != nil {
+					return err
+				}
+				activity.Action = models.ActivityNoteCopy
+				before["id"] = id
+			} else {
+				note.NotebookID = notebookID
+				if err := tx.Model(&note).Update("notebook_id", notebookID).Error; err != nil {
+					return err
+				}
+			}
+
+			activity.TargetID = uintPtr(uint(note.ID))
+			result = append(result, note)
+			activities = append(activities, activity)
+			befores = append(befores, before)
+		}
+		return nil
+	})
+	if errors.Is(err, errQuotaExceeded) {
+		return nil, false
+	}
+	if err != nil {
+		if copyNote {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to copy notes"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move notes"})
+		}
+		return nil, false
+	}
+
+	for i, activity := range activities {
+		recordActivity(c, activity, befores[i], noteSummary(result[i]))
+		if copyNote {
+			afterNoteSaved(result[i], "")
+		}
+	}
+
+	return result, true
+}
@@ -1,11 +1,13 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-resty/resty/v2"
+	"gorm.io/gorm"
 
 	"noteapp-framework-backend/config"
 	"noteapp-framework-backend/models"
@@ -13,12 +15,19 @@ import (
 
 // CreateNotebook creates a new notebook
 func CreateNotebook(c *gin.Context) {
-	var notebook models.Notebook
-	if err := c.ShouldBindJSON(&notebook); err != nil {
+	var input struct {
+		Name     string `json:"name" binding:"required"`
+		ParentID *uint  `json:"parent_id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	// Encrypted notebooks are created with their key by CreateEncryptedNotebook, and notebooks are
+	// archived through ArchiveNotebook
+	notebook := models.Notebook{Name: input.Name, ParentID: input.ParentID}
+
 	// Set user_id in notebook.
 	userID, exists := c.Get("user_id")
 	if !exists {
@@ -36,38 +45,129 @@ func CreateNotebook(c *gin.Context) {
 		return
 	}
 	notebook.UserID = uint(userIDUint)
+	notebook.WorkspaceID = c.GetUint("workspace_id")
+
+	// A sub-notebook can only be created inside a notebook of the same workspace
+	if notebook.ParentID != nil {
+		var parent models.Notebook
+		if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", *notebook.ParentID).First(&parent).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Parent notebook not found or access denied"})
+			return
+		}
+	}
 
-	if err := config.DB.Create(&notebook).Error; err != nil {
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if !enforceQuota(c, tx, userID, quotaNotebooks, 1) {
+			return errQuotaExceeded
+		}
+		return tx.Create(&notebook).Error
+	})
+	if errors.Is(err, errQuotaExceeded) {
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create notebook"})
 		return
 	}
 
+	recordActivity(c, models.Activity{
+		Action:     models.ActivityNotebookCreate,
+		TargetType: "notebook",
+		TargetID:   uintPtr(uint(notebook.ID)),
+		NotebookID: uintPtr(uint(notebook.ID)),
+	}, nil, notebookSummary(notebook))
+
 	c.JSON(http.StatusCreated, notebook)
 }
 
-// GetNotebooks retrieves all notebooks for user_id
+// notebookSortColumns maps the ?sort= keys of notebook listings to their column.
+var notebookSortColumns = map[string]string{
+	"created": "created_at",
+	"updated": "updated_at",
+	"name":    "name",
+}
+
+// GetNotebooks retrieves the notebooks of the active workspace.
+// The listing is sorted with ?sort=created|updated|name (prefix with "-" for descending order) and
+// filtered with the created_*/updated_* date ranges and ?name_prefix=. Passing ?limit= or ?cursor=
+// switches to keyset pagination. Archived notebooks are only listed with ?archived=true.
 func GetNotebooks(c *gin.Context) {
-	userID, exists := c.Get("user_id")
+	_, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
 		return
 	}
 
+	sort, err := parseListSort(c.DefaultQuery("sort", "created"), notebookSortColumns)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query, err := applyListFilters(c, config.DB.Model(&models.Notebook{}).Scopes(inWorkspace(c)).Where("archived = ?", showArchived(c)), "name_prefix", "name")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, paginated := c.GetQuery("limit")
+	cursor := c.Query("cursor")
+	if !paginated && cursor == "" {
+		var notebooks []models.Notebook
+		if err := query.Order(sort.order()).Find(&notebooks).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notebooks"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data": notebooks,
+		})
+		return
+	}
+
+	limitInt, err := parsePageSize(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if cursor != "" {
+		query, err = applyListCursor(query, sort, cursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// Fetch one extra notebook to know whether there is a next page
 	var notebooks []models.Notebook
-	if err := config.DB.Where("user_id = ?", userID).Find(&notebooks).Error; err != nil {
+	if err := query.Order(sort.order()).Limit(limitInt + 1).Find(&notebooks).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notebooks"})
 		return
 	}
 
+	var nextCursor *string
+	if len(notebooks) > limitInt {
+		notebooks = notebooks[:limitInt]
+		next, err := nextListCursor(config.DB.Scopes(inWorkspace(c)), &models.Notebook{}, sort, notebooks[len(notebooks)-1].ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build cursor"})
+			return
+		}
+		nextCursor = &next
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"data": notebooks,
+		"data":        notebooks,
+		"limit":       limitInt,
+		"next_cursor": nextCursor,
 	})
 }
 
 // GetNotebook retrieves a single notebook by ID
 func GetNotebook(c *gin.Context) {
 	// Retrieve user ID from the context
-	userID, exists := c.Get("user_id")
+	_, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
 		return
@@ -76,18 +176,24 @@ func GetNotebook(c *gin.Context) {
 	id := c.Param("id")
 	var notebook models.Notebook
 
-	if err := config.DB.Where("id = ? AND user_id = ?", id, userID).First(&notebook).Error; err != nil {
+	if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", id).First(&notebook).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Notebook not found or access denied"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": notebook})
+	breadcrumbs, err := notebookBreadcrumbs(notebook)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notebook ancestors"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": notebook, "breadcrumbs": breadcrumbs})
 }
 
 // UpdateNotebook updates an existing notebook
 func UpdateNotebook(c *gin.Context) {
 	// Retrieve user ID from the context
-	userID, exists := c.Get("user_id")
+	_, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
 		return
@@ -97,34 +203,42 @@ func UpdateNotebook(c *gin.Context) {
 	var notebook models.Notebook
 
 	// Fetch the notebook and ensure it belongs to the authenticated user
-	if err := config.DB.Where("id = ? AND user_id = ?", id, userID).First(&notebook).Error; err != nil {
+	if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", id).First(&notebook).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Notebook not found or access denied"})
 		return
 	}
 
-	// Bind the updated data (but keep the original user_id)
-	var updatedData models.Notebook
-	if err := c.ShouldBindJSON(&updatedData); err != nil {
+	// Only the name can be changed here, the other fields have handlers of their own
+	var input struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Update only the fields that are allowed to change
-	notebook.Name = updatedData.Name
+	before := notebookSummary(notebook)
+	notebook.Name = input.Name
 
-	// Save the updated notebook
-	if err := config.DB.Save(&notebook).Error; err != nil {
+	if err := config.DB.Model(&notebook).Select("name").Updates(&notebook).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notebook"})
 		return
 	}
 
+	recordActivity(c, models.Activity{
+		Action:     models.ActivityNotebookUpdate,
+		TargetType: "notebook",
+		TargetID:   uintPtr(uint(notebook.ID)),
+		NotebookID: uintPtr(uint(notebook.ID)),
+	}, before, notebookSummary(notebook))
+
 	c.JSON(http.StatusOK, gin.H{"data": notebook})
 }
 
-// DeleteNotebook deletes a notebook by ID
+// DeleteNotebook deletes a notebook by ID, together with its sub-notebooks
 func DeleteNotebook(c *gin.Context) {
 	// Retrieve user ID from the context
-	userID, exists := c.Get("user_id")
+	_, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
 		return
@@ -134,29 +248,52 @@ func DeleteNotebook(c *gin.Context) {
 	var notebook models.Notebook
 
 	// Fetch the notebook and ensure it belongs to the authenticated user
-	if err := config.DB.Where("id = ? AND user_id = ?", id, userID).First(&notebook).Error; err != nil {
+	if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", id).First(&notebook).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Notebook not found or access denied"})
 		return
 	}
 
-	// Delete all notes associated with the notebook
-	if err := config.DB.Where("notebook_id = ?", id).Delete(&models.Note{}).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete associated notes"})
+	subtree, _, err := notebookSubtree(notebook)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sub-notebooks"})
 		return
 	}
+	notebookIDs := make([]int, 0, len(subtree))
+	for _, nb := range subtree {
+		notebookIDs = append(notebookIDs, nb.ID)
+	}
 
-	// Delete the notebook
-	if err := config.DB.Delete(&notebook).Error; err != nil {
+	// Delete the notes of the notebook and its sub-notebooks together with the notebooks, so a
+	// failure leaves all of them in place
+	var hashes []string
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		hashes = attachmentHashes(tx, tx.Model(&models.Note{}).Select("id").Where("notebook_id IN ?", notebookIDs))
+		if err := tx.Where("notebook_id IN ?", notebookIDs).Delete(&models.Note{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", notebookIDs).Delete(&models.Notebook{}).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete notebook"})
 		return
 	}
+	pruneAttachmentBlobs(hashes)
+
+	for _, nb := range subtree {
+		recordActivity(c, models.Activity{
+			Action:     models.ActivityNotebookDelete,
+			TargetType: "notebook",
+			TargetID:   uintPtr(uint(nb.ID)),
+			NotebookID: uintPtr(uint(nb.ID)),
+		}, notebookSummary(nb), nil)
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Notebook deleted successfully"})
 }
 
 func GetNoteCount(c *gin.Context) {
 	// Retrieve user ID from the context
-	userID, exists := c.Get("user_id")
+	_, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
 		return
@@ -165,13 +302,13 @@ func GetNoteCount(c *gin.Context) {
 	notebookID := c.Param("notebookid")
 
 	var notebook models.Notebook
-	if err := config.DB.Where("id = ? AND user_id = ?", notebookID, userID).First(&notebook).Error; err != nil {
+	if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", notebookID).First(&notebook).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Notebook not found or access denied"})
 		return
 	}
 
 	var noteCount int64
-	if err := config.DB.Model(&models.Note{}).Where("notebook_id = ?", notebookID).Count(&noteCount).Error; err != nil {
+	if err := config.DB.Model(&models.Note{}).Where("notebook_id = ? AND archived = ?", notebookID, false).Count(&noteCount).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notes"})
 		return
 	}
@@ -181,14 +318,14 @@ func GetNoteCount(c *gin.Context) {
 
 func GetNotebookCount(c *gin.Context) {
 	// Retrieve user ID from the context
-	userID, exists := c.Get("user_id")
+	_, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
 		return
 	}
 
 	var notebookCount int64
-	if err := config.DB.Model(&models.Notebook{}).Where("user_id = ?", userID).Count(&notebookCount).Error; err != nil {
+	if err := config.DB.Model(&models.Notebook{}).Scopes(inWorkspace(c)).Where("archived = ?", false).Count(&notebookCount).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notebooks"})
 		return
 	}
@@ -198,7 +335,7 @@ func GetNotebookCount(c *gin.Context) {
 
 func GetNotebookName(c *gin.Context) {
 	// Retrieve user ID from the context
-	userID, exists := c.Get("user_id")
+	_, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
 		return
@@ -207,7 +344,7 @@ func GetNotebookName(c *gin.Context) {
 	notebookID := c.Param("id")
 
 	var notebook models.Notebook
-	if err := config.DB.Where("id = ? AND user_id = ?", notebookID, userID).First(&notebook).Error; err != nil {
+	if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", notebookID).First(&notebook).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Notebook not found or access denied"})
 		return
 	}
@@ -215,7 +352,7 @@ func GetNotebookName(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"notebook_name": notebook.Name})
 }
 
-// ExportNotebook forwards the export request to the export-service
+// ExportNotebook forwards the export request to the export-service, including the notes of all sub-notebooks
 func ExportNotebook(c *gin.Context) {
 	notebookID := c.Param("id")
 
@@ -227,17 +364,49 @@ func ExportNotebook(c *gin.Context) {
 
 	// Fetch the notebook from the database
 	var notebook models.Notebook
-	if err := config.DB.Where("id = ? AND user_id = ?", notebookID, userID).First(&notebook).Error; err != nil {
+	if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", notebookID).First(&notebook).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Notebook not found or access denied"})
 		return
 	}
 
-	// Fetch the notes associated with the notebook
-	var notes []models.Note
-	if err := config.DB.Where("notebook_id = ?", notebookID).Find(&notes).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notes for the notebook"})
+	subtree, paths, err := notebookSubtree(notebook)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sub-notebooks"})
 		return
 	}
+	for _, nb := range subtree {
+		if rejectEncrypted(c, nb) {
+			return
+		}
+	}
+
+	// Fetch the notes associated with the notebook and its sub-notebooks, grouped by notebook
+	type exportedNote struct {
+		Title    string            `json:"title"`
+		Content  string            `json:"content"`
+		Notebook string            `json:"notebook"`
+		Images   map[string]string `json:"images"`
+	}
+	var notes []exportedNote
+	for _, nb := range subtree {
+		var notebookNotes []models.Note
+		if err := config.DB.Scopes(inWorkspace(c)).Where("notebook_id = ?", nb.ID).Find(&notebookNotes).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notes for the notebook"})
+			return
+		}
+		for _, note := range notebookNotes {
+			// Locked notes are exported with their title only, unless unlocked in the session
+			if !decryptUnlockedNote(c, &note) {
+				note.Content = ""
+			}
+			notes = append(notes, exportedNote{
+				Title:    note.Title,
+				Content:  note.Content,
+				Notebook: paths[nb.ID],
+				Images:   noteExportImages(c, note.Content),
+			})
+		}
+	}
 
 	// Prepare the request body for the export-service
 	requestBody := map[string]interface{}{
@@ -245,6 +414,16 @@ func ExportNotebook(c *gin.Context) {
 		"notes":         notes,
 	}
 
+	activity := models.Activity{
+		Action:     models.ActivityNotebookExport,
+		TargetType: "notebook",
+		TargetID:   uintPtr(uint(notebook.ID)),
+		NotebookID: uintPtr(uint(notebook.ID)),
+	}
+	if !reserveExport(c, userID, &activity) {
+		return
+	}
+
 	// Call the export-service
 	client := resty.New()
 	resp, err := client.R().
@@ -253,10 +432,16 @@ func ExportNotebook(c *gin.Context) {
 		Post("http://localhost:8081/export/notebook")
 
 	if err != nil {
+		releaseExport(activity)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export notebook"})
 		return
 	}
 
+	// Only successful exports count towards the daily export quota
+	if !resp.IsSuccess() {
+		releaseExport(activity)
+	}
+
 	// Forward the response from the export-service
 	c.Data(resp.StatusCode(), resp.Header().Get("Content-Type"), resp.Body())
 }
@@ -53,12 +53,15 @@ func initNotebookTestDB() {
 
 	// Insert a test user
 	config.DB.Exec("INSERT INTO users (id, username, password) VALUES (1, 'Test', 'password')")
+	config.DB.Exec("INSERT INTO workspaces (id, name, personal_user_id) VALUES (1, 'Personal', 1)")
+	config.DB.Exec("INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (1, 1, 'admin')")
 }
 
 func mockNotebookAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Simulate an authenticated user by setting a user ID in the context
 		c.Set("user_id", "1")
+		c.Set("workspace_id", uint(1))
 		c.Next()
 	}
 }
@@ -88,7 +91,7 @@ func TestGetNotebooks(t *testing.T) {
 	router := setupNotebookTestRouter()
 
 	// Insert notebook manually for test
-	config.DB.Create(&models.Notebook{Name: "Test Notebook", UserID: 1})
+	config.DB.Create(&models.Notebook{Name: "Test Notebook", UserID: 1, WorkspaceID: 1})
 
 	req, _ := http.NewRequest("GET", "/notebooks", nil)
 	w := httptest.NewRecorder()
@@ -110,7 +113,7 @@ func TestGetNotebook(t *testing.T) {
 	router := setupNotebookTestRouter()
 
 	// Insert notebook manually for test
-	notebook := models.Notebook{ID: 1, Name: "Test Notebook", UserID: 1}
+	notebook := models.Notebook{ID: 1, Name: "Test Notebook", UserID: 1, WorkspaceID: 1}
 	config.DB.Create(&notebook)
 
 	req, _ := http.NewRequest("GET", "/notebooks/1", nil)
@@ -132,7 +135,7 @@ func TestUpdateNotebook(t *testing.T) {
 	router := setupNotebookTestRouter()
 
 	// Insert a notebook manually for the test
-	notebook := models.Notebook{ID: 1, Name: "Old Notebook Name", UserID: 1}
+	notebook := models.Notebook{ID: 1, Name: "Old Notebook Name", UserID: 1, WorkspaceID: 1}
 	config.DB.Create(&notebook)
 
 	updatedNotebook := models.Notebook{Name: "Updated Notebook Name"}
@@ -162,7 +165,7 @@ func TestDeleteNotebook(t *testing.T) {
 	router := setupNotebookTestRouter()
 
 	// Insert a notebook manually for the test
-	notebook := models.Notebook{ID: 1, Name: "Test Notebook", UserID: 1}
+	notebook := models.Notebook{ID: 1, Name: "Test Notebook", UserID: 1, WorkspaceID: 1}
 	config.DB.Create(&notebook)
 
 	req, _ := http.NewRequest("DELETE", "/notebooks/1", nil)
@@ -189,8 +192,8 @@ func TestGetNotebookCount(t *testing.T) {
 	router := setupNotebookTestRouter()
 
 	// Insert notebooks manually for the test
-	config.DB.Create(&models.Notebook{Name: "Notebook 1", UserID: 1})
-	config.DB.Create(&models.Notebook{Name: "Notebook 2", UserID: 1})
+	config.DB.Create(&models.Notebook{Name: "Notebook 1", UserID: 1, WorkspaceID: 1})
+	config.DB.Create(&models.Notebook{Name: "Notebook 2", UserID: 1, WorkspaceID: 1})
 
 	req, _ := http.NewRequest("GET", "/notebookscount/", nil)
 	w := httptest.NewRecorder()
@@ -211,7 +214,7 @@ func TestGetNoteCount(t *testing.T) {
 	router := setupNotebookTestRouter()
 
 	// Insert a notebook manually
-	notebook := models.Notebook{ID: 1, Name: "Test Notebook", UserID: 1}
+	notebook := models.Notebook{ID: 1, Name: "Test Notebook", UserID: 1, WorkspaceID: 1}
 	config.DB.Create(&notebook)
 
 	// Insert notes associated with the notebook manually
@@ -237,7 +240,7 @@ func TestGetNotebookName(t *testing.T) {
 	router := setupNotebookTestRouter()
 
 	// Insert a notebook manually for the test
-	notebook := models.Notebook{ID: 1, Name: "Test Notebook", UserID: 1}
+	notebook := models.Notebook{ID: 1, Name: "Test Notebook", UserID: 1, WorkspaceID: 1}
 	config.DB.Create(&notebook)
 
 	req, _ := http.NewRequest("GET", "/notebookname/1", nil)
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/models"
+)
+
+var errNotebookCycle = errors.New("a notebook cannot be moved into itself or one of its descendants")
+
+// notebookNode is a notebook with its children, as returned by GET /notebooks/tree
+type notebookNode struct {
+	models.Notebook
+	NoteCount int64           `json:"note_count"`
+	Children  []*notebookNode `json:"children"`
+}
+
+type breadcrumb struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// GetNotebookTree retrieves the full notebook hierarchy of the active workspace with note counts.
+// Archived notebooks (and everything below them) and archived notes are left out.
+func GetNotebookTree(c *gin.Context) {
+	_, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var notebooks []models.Notebook
+	if err := config.DB.Scopes(inWorkspace(c)).Where("archived = ?", false).Order("name").Find(&notebooks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notebooks"})
+		return
+	}
+
+	var counts []struct {
+		NotebookID int
+		Count      int64
+	}
+	if err := config.DB.Model(&models.Note{}).
+		Select("notebook_id, COUNT(*) AS count").
+		Scopes(inWorkspace(c)).
+		Where("archived = ?", false).
+		Group("notebook_id").
+		Scan(&counts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notes"})
+		return
+	}
+
+	nodes := make(map[int]*notebookNode, len(notebooks))
+	for _, notebook := range notebooks {
+		nodes[notebook.ID] = &notebookNode{Notebook: notebook, Children: []*notebookNode{}}
+	}
+	for _, count := range counts {
+		if node, ok := nodes[count.NotebookID]; ok {
+			node.NoteCount = count.Count
+		}
+	}
+
+	roots := []*notebookNode{}
+	for _, notebook := range notebooks {
+		node := nodes[notebook.ID]
+		if notebook.ParentID != nil {
+			// A missing parent is archived, which hides this notebook as well
+			if parent, ok := nodes[int(*notebook.ParentID)]; ok {
+				parent.Children = append(parent.Children, node)
+			}
+			continue
+		}
+		roots = append(roots, node)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": roots})
+}
+
+// MoveNotebook moves a notebook under another notebook, or to the top level when parent_id is null
+func MoveNotebook(c *gin.Context) {
+	_, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var input struct {
+		ParentID *uint `json:"parent_id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	id := c.Param("id")
+	var notebook models.Notebook
+	if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", id).First(&notebook).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notebook not found or access denied"})
+		return
+	}
+
+	if input.ParentID != nil {
+		var parent models.Notebook
+		if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", *input.ParentID).First(&parent).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Parent notebook not found or access denied"})
+			return
+		}
+
+		if err := checkNotebookCycle(notebook.ID, parent); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	before := gin.H{"parent_id": notebook.ParentID}
+	notebook.ParentID = input.ParentID
+
+	if err := config.DB.Model(&notebook).Update("parent_id", notebook.ParentID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move notebook"})
+		return
+	}
+
+	recordActivity(c, models.Activity{
+		Action:     models.ActivityNotebookMove,
+		TargetType: "notebook",
+		TargetID:   uintPtr(uint(notebook.ID)),
+		NotebookID: uintPtr(uint(notebook.ID)),
+	}, before, gin.H{"parent_id": notebook.ParentID})
+
+	c.JSON(http.StatusOK, gin.H{"data": notebook})
+}
+
+// Private helper functions.
+
+// checkNotebookCycle walks up from the new parent and fails if it reaches the notebook being moved.
+func checkNotebookCycle(notebookID int, parent models.Notebook) error {
+	current := parent
+	for depth := 0; ; depth++ {
+		if current.ID == notebookID || depth > 1000 {
+			return errNotebookCycle
+		}
+		if current.ParentID == nil {
+			return nil
+		}
+		var next models.Notebook
+		if err := config.DB.First(&next, *current.ParentID).Error; err != nil {
+			return err
+		}
+		current = next
+	}
+}
+
+// notebookBreadcrumbs returns the ancestors of the notebook, from the root down to its parent.
+func notebookBreadcrumbs(notebook models.Notebook) ([]breadcrumb, error) {
+	breadcrumbs := []breadcrumb{}
+	current := notebook
+	for current.ParentID != nil && len(breadcrumbs) < 1000 {
+		var parent models.Notebook
+		if err := config.DB.Scopes(workspaceScope(notebook.WorkspaceID)).Where("id = ?", *current.ParentID).First(&parent).Error; err != nil {
+			return nil, err
+		}
+		breadcrumbs = append([]breadcrumb{{ID: parent.ID, Name: parent.Name}}, breadcrumbs...)
+		current = parent
+	}
+	return breadcrumbs, nil
+}
+
+// notebookSubtree returns the notebook and all of its descendants, mapped to their
+// path relative to the notebook (e.g. "Projects / Backend"), sorted by path.
+func notebookSubtree(root models.Notebook) ([]models.Notebook, map[int]string, error) {
+	var notebooks []models.Notebook
+	if err := config.DB.Scopes(workspaceScope(root.WorkspaceID)).Find(&notebooks).Error; err != nil {
+		return nil, nil, err
+	}
+
+	children := make(map[uint][]models.Notebook)
+	for _, notebook := range notebooks {
+		if notebook.ParentID != nil {
+			children[*notebook.ParentID] = append(children[*notebook.ParentID], notebook)
+		}
+	}
+
+	paths := map[int]string{root.ID: root.Name}
+	subtree := []models.Notebook{root}
+	for i := 0; i < len(subtree); i++ {
+		for _, child := range children[uint(subtree[i].ID)] {
+			if _, seen := paths[child.ID]; seen {
+				continue
+			}
+			paths[child.ID] = strings.Join([]string{paths[subtree[i].ID], child.Name}, " / ")
+			subtree = append(subtree, child)
+		}
+	}
+
+	sort.SliceStable(subtree, func(i, j int) bool {
+		return paths[subtree[i].ID] < paths[subtree[j].ID]
+	})
+
+	return subtree, paths, nil
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/models"
+	"noteapp-framework-backend/notifications"
+)
+
+// GetNotifications retrieves the notifications of the user, newest first
+func GetNotifications(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	pageInt, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || pageInt < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page"})
+		return
+	}
+	limitInt, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limitInt < 1 || limitInt > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+
+	query := config.DB.Model(&models.Notification{}).Where("user_id = ?", userID)
+	if c.Query("unread") == "true" {
+		query = query.Where("read = ?", false)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications"})
+		return
+	}
+
+	var notificationList []models.Notification
+	if err := query.Order("created_at DESC, id DESC").Limit(limitInt).Offset((pageInt - 1) * limitInt).Find(&notificationList).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+		return
+	}
+
+	var unread int64
+	config.DB.Model(&models.Notification{}).Where("user_id = ? AND read = ?", userID, false).Count(&unread)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       notificationList,
+		"unread":     unread,
+		"total":      total,
+		"page":       pageInt,
+		"limit":      limitInt,
+		"totalPages": int(math.Ceil(float64(total) / float64(limitInt))),
+	})
+}
+
+// MarkNotificationRead marks a single notification as read
+func MarkNotificationRead(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	id := c.Param("id")
+	var notification models.Notification
+
+	if err := config.DB.Where("id = ? AND user_id = ?", id, userID).First(&notification).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found or access denied"})
+		return
+	}
+
+	notification.Read = true
+	if err := config.DB.Model(&notification).Update("read", true).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": notification})
+}
+
+// MarkAllNotificationsRead marks every unread notification of the user as read
+func MarkAllNotificationsRead(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	result := config.DB.Model(&models.Notification{}).Where("user_id = ? AND read = ?", userID, false).Update("read", true)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": result.RowsAffected})
+}
+
+// GetNotificationPreferences returns whether each event type notifies the user
+func GetNotificationPreferences(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var stored []models.NotificationPreference
+	if err := config.DB.Where("user_id = ?", userID).Find(&stored).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notification preferences"})
+		return
+	}
+
+	preferences := make(map[string]bool, len(models.NotificationEventTypes))
+	for _, eventType := range models.NotificationEventTypes {
+		preferences[eventType] = true
+	}
+	for _, preference := range stored {
+		preferences[preference.EventType] = preference.Enabled
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": preferences})
+}
+
+// UpdateNotificationPreferences enables or disables notifications per event type
+func UpdateNotificationPreferences(c *gin.Context) {
+	var input map[string]bool
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+	userIDUint, err := strconv.ParseUint(userID.(string), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	known := make(map[string]bool, len(models.NotificationEventTypes))
+	for _, eventType := range models.NotificationEventTypes {
+		known[eventType] = true
+	}
+	for eventType := range input {
+		if !known[eventType] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown event type: " + eventType})
+			return
+		}
+	}
+
+	for eventType, enabled := range input {
+		preference := models.NotificationPreference{UserID: uint(userIDUint), EventType: eventType, Enabled: enabled}
+		if err := config.DB.Save(&preference).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification preferences"})
+			return
+		}
+	}
+
+	GetNotificationPreferences(c)
+}
+
+// StreamNotifications pushes new notifications to the client as server-sent events
+func StreamNotifications(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+	userIDUint, err := strconv.ParseUint(userID.(string), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	stream, unsubscribe := notifications.Subscribe(uint(userIDUint))
+	defer unsubscribe()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case notification := <-stream:
+			c.SSEvent("notification", notification)
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
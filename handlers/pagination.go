@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageSize = 10
+	maxPageSize     = 100
+)
+
+var errInvalidCursor = errors.New("Invalid cursor")
+
+// listSort is a validated ?sort= parameter, e.g. "-updated" sorts by updated_at descending.
+// PinnedFirst puts pinned rows before all others, whatever the sort.
+type listSort struct {
+	Key         string
+	Column      string
+	Desc        bool
+	PinnedFirst bool
+}
+
+// listCursor is the decoded form of the opaque cursor handed to clients.
+type listCursor struct {
+	Sort   string `json:"s"`
+	Value  string `json:"v"`
+	ID     int    `json:"id"`
+	Pinned *bool  `json:"p,omitempty"`
+}
+
+// parseListSort validates the sort parameter against the columns allowed for the listing.
+func parseListSort(value string, columns map[string]string) (listSort, error) {
+	sort := listSort{Key: strings.TrimPrefix(value, "-"), Desc: strings.HasPrefix(value, "-")}
+	column, ok := columns[sort.Key]
+	if !ok {
+		return listSort{}, errors.New("Invalid sort")
+	}
+	sort.Column = column
+	return sort, nil
+}
+
+// order returns the ORDER BY clause, with the id as tie-breaker so the order is stable.
+func (s listSort) order() string {
+	order := s.Column + " ASC, id ASC"
+	if s.Desc {
+		order = s.Column + " DESC, id DESC"
+	}
+	if s.PinnedFirst {
+		order = "pinned DESC, " + order
+	}
+	return order
+}
+
+// parsePageSize reads ?limit=, rejecting invalid values and capping it at maxPageSize.
+func parsePageSize(c *gin.Context) (int, error) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultPageSize)))
+	if err != nil || limit < 1 {
+		return 0, errors.New("Invalid limit")
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	return limit, nil
+}
+
+// applyListFilters applies the created_after/created_before/updated_after/updated_before
+// date filters and the prefix filter on the given text column.
+func applyListFilters(c *gin.Context, query *gorm.DB, prefixParam, prefixColumn string) (*gorm.DB, error) {
+	filters := []struct {
+		param, condition string
+	}{
+		{"created_after", "created_at >= ?"},
+		{"created_before", "created_at < ?"},
+		{"updated_after", "updated_at >= ?"},
+		{"updated_before", "updated_at < ?"},
+	}
+	for _, filter := range filters {
+		value := c.Query(filter.param)
+		if value == "" {
+			continue
+		}
+		t, err := parseDateParam(value)
+		if err != nil {
+			return nil, errors.New("Invalid " + filter.param)
+		}
+		query = query.Where(filter.condition, t)
+	}
+
+	if prefix := c.Query(prefixParam); prefix != "" {
+		escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix)
+		query = query.Where(prefixColumn+" ILIKE ?", escaped+"%")
+	}
+
+	return query, nil
+}
+
+// applyListCursor restricts the query to the rows after the cursor in the given sort order.
+func applyListCursor(query *gorm.DB, sort listSort, cursor string) (*gorm.DB, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var decoded listCursor
+	if err := json.Unmarshal(raw, &decoded); err != nil || decoded.Sort != sort.Key {
+		return nil, errInvalidCursor
+	}
+	if sort.PinnedFirst != (decoded.Pinned != nil) {
+		return nil, errInvalidCursor
+	}
+
+	var value interface{} = decoded.Value
+	if sort.Key != "title" && sort.Key != "name" {
+		t, err := time.Parse(time.RFC3339Nano, decoded.Value)
+		if err != nil {
+			return nil, errInvalidCursor
+		}
+		value = t
+	}
+
+	operator := ">"
+	if sort.Desc {
+		operator = "<"
+	}
+	condition := "(" + sort.Column + " " + operator + " ?) OR (" + sort.Column + " = ? AND id " + operator + " ?)"
+	if !sort.PinnedFirst {
+		return query.Where(condition, value, value, decoded.ID), nil
+	}
+
+	// Pinned rows come first, so after a pinned row the unpinned ones follow
+	return query.Where(
+		"(pinned < ?) OR (pinned = ? AND ("+condition+"))",
+		*decoded.Pinned, *decoded.Pinned, value, value, decoded.ID,
+	), nil
+}
+
+// nextListCursor builds the cursor pointing after the row with the given id, looked up through db,
+// which callers scope to the active workspace like the listing itself.
+func nextListCursor(db *gorm.DB, model interface{}, sort listSort, lastID int) (string, error) {
+	var value interface{}
+	var pinned bool
+	if sort.PinnedFirst {
+		if err := db.Model(model).Select(sort.Column+", pinned").Where("id = ?", lastID).Row().Scan(&value, &pinned); err != nil {
+			return "", err
+		}
+	} else if err := db.Model(model).Select(sort.Column).Where("id = ?", lastID).Row().Scan(&value); err != nil {
+		return "", err
+	}
+
+	cursor := listCursor{Sort: sort.Key, ID: lastID}
+	if sort.PinnedFirst {
+		cursor.Pinned = &pinned
+	}
+	switch v := value.(type) {
+	case time.Time:
+		cursor.Value = v.Format(time.RFC3339Nano)
+	case string:
+		cursor.Value = v
+	}
+
+	raw, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
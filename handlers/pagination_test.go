@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseListSort(t *testing.T) {
+	sort, err := parseListSort("-updated", noteSortColumns)
+	assert.NoError(t, err)
+	assert.Equal(t, "updated", sort.Key)
+	assert.Equal(t, "updated_at DESC, id DESC", sort.order())
+
+	sort, err = parseListSort("title", noteSortColumns)
+	assert.NoError(t, err)
+	assert.Equal(t, "title ASC, id ASC", sort.order())
+
+	sort.PinnedFirst = true
+	assert.Equal(t, "pinned DESC, title ASC, id ASC", sort.order())
+
+	_, err = parseListSort("user_id", noteSortColumns)
+	assert.Error(t, err)
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/models"
+)
+
+// Quota names, as reported by GET /me/usage and in quota errors.
+const (
+	quotaNotebooks       = "notebooks"
+	quotaNotes           = "notes"
+	quotaAttachmentBytes = "attachment_bytes"
+	quotaExportsPerDay   = "exports_per_day"
+)
+
+// errQuotaExceeded rolls back the transaction of a request after enforceQuota refused it and wrote the response.
+var errQuotaExceeded = errors.New("quota exceeded")
+
+// GetUsage reports the consumption of the user against each of their quotas. A null limit is unlimited.
+func GetUsage(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	usage, err := userUsage(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": usage})
+}
+
+// Private helper functions.
+
+// userUsage reports the consumption of the user against each of their quotas.
+func userUsage(userID interface{}) (gin.H, error) {
+	limits := userQuotas(userID)
+	usage := gin.H{}
+	for _, quota := range []string{quotaNotebooks, quotaNotes, quotaAttachmentBytes, quotaExportsPerDay} {
+		used, err := quotaUsed(config.DB, userID, quota)
+		if err != nil {
+			return nil, err
+		}
+
+		entry := gin.H{"used": used, "limit": nil}
+		if limit := quotaLimit(limits, quota); limit > 0 {
+			entry["limit"] = limit
+		}
+		usage[quota] = entry
+	}
+	if resetsAt, err := exportQuotaReset(userID); err == nil {
+		usage[quotaExportsPerDay].(gin.H)["resets_at"] = resetsAt
+	}
+	return usage, nil
+}
+
+// enforceQuota checks that the user can add the given amount to a quota. The check holds the
+// quota lock of the user until tx ends, so the additions must be made in tx: concurrent requests
+// then see them and cannot overshoot the limit together. When the quota would be exceeded it
+// writes the error response and returns false: 403 for the storage quotas, 429 with Retry-After
+// for the daily export quota.
+func enforceQuota(c *gin.Context, tx *gorm.DB, userID interface{}, quota string, adding int64) bool {
+	limit := quotaLimit(userQuotas(userID), quota)
+	if limit <= 0 {
+		return true
+	}
+
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", fmt.Sprintf("quota:%v", userID)).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check quota"})
+		return false
+	}
+	used, err := quotaUsed(tx, userID, quota)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check quota"})
+		return false
+	}
+	if used+adding <= limit {
+		return true
+	}
+
+	response := gin.H{
+		"error": fmt.Sprintf("Quota exceeded: %s", quota),
+		"quota": quota,
+		"limit": limit,
+		"used":  used,
+	}
+	if quota != quotaExportsPerDay {
+		c.JSON(http.StatusForbidden, response)
+		return false
+	}
+
+	if resetsAt, err := exportQuotaReset(userID); err == nil {
+		response["resets_at"] = resetsAt
+		c.Header("Retry-After", strconv.Itoa(int(time.Until(resetsAt).Seconds())+1))
+	}
+	c.JSON(http.StatusTooManyRequests, response)
+	return false
+}
+
+// reserveExport counts an export towards the daily export quota before it runs by recording its
+// activity, so concurrent exports cannot exceed the quota together. It writes the error response
+// and returns false when the export is refused.
+func reserveExport(c *gin.Context, userID interface{}, activity *models.Activity) bool {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if !enforceQuota(c, tx, userID, quotaExportsPerDay, 1) {
+			return errQuotaExceeded
+		}
+		fillActivity(c, activity, nil, nil)
+		return tx.Create(activity).Error
+	})
+	if errors.Is(err, errQuotaExceeded) {
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check quota"})
+		return false
+	}
+	return true
+}
+
+// releaseExport gives the quota of a failed export back: only successful exports are logged and counted.
+func releaseExport(activity models.Activity) {
+	if err := config.DB.Delete(&activity).Error; err != nil {
+		log.Printf("Failed to release export %d: %v", activity.ID, err)
+	}
+}
+
+// userQuotas returns the limits of the user: the defaults, overridden by their own limits.
+func userQuotas(userID interface{}) config.Quotas {
+	limits := config.GetDefaultQuotas()
+
+	var quota models.UserQuota
+	if err := config.DB.Where("user_id = ?", userID).Limit(1).Find(&quota).Error; err != nil || quota.UserID == 0 {
+		return limits
+	}
+	if quota.MaxNotebooks != nil {
+		limits.Notebooks = *quota.MaxNotebooks
+	}
+	if quota.MaxNotes != nil {
+		limits.Notes = *quota.MaxNotes
+	}
+	if quota.MaxAttachmentBytes != nil {
+		limits.AttachmentBytes = *quota.MaxAttachmentBytes
+	}
+	if quota.MaxExportsPerDay != nil {
+		limits.ExportsPerDay = *quota.MaxExportsPerDay
+	}
+	return limits
+}
+
+func quotaLimit(limits config.Quotas, quota string) int64 {
+	switch quota {
+	case quotaNotebooks:
+		return limits.Notebooks
+	case quotaNotes:
+		return limits.Notes
+	case quotaAttachmentBytes:
+		return limits.AttachmentBytes
+	default:
+		return limits.ExportsPerDay
+	}
+}
+
+// quotaUsed computes the current consumption of a quota. Exports are counted in the
+// activity log since the start of the day in the user's time zone.
+func quotaUsed(db *gorm.DB, userID interface{}, quota string) (int64, error) {
+	var used int64
+	var err error
+
+	switch quota {
+	case quotaNotebooks:
+		err = db.Model(&models.Notebook{}).Where("user_id = ?", userID).Count(&used).Error
+	case quotaNotes:
+		err = db.Model(&models.Note{}).Where("user_id = ?", userID).Count(&used).Error
+	case quotaAttachmentBytes:
+		err = db.Model(&models.Attachment{}).Where("user_id = ?", userID).Select("COALESCE(SUM(size), 0)").Scan(&used).Error
+	default:
+		var resetsAt time.Time
+		if resetsAt, err = exportQuotaReset(userID); err == nil {
+			err = db.Model(&models.Activity{}).
+				Where("actor_id = ? AND action IN ? AND created_at >= ?", userID,
+					[]string{models.ActivityNoteExport, models.ActivityNotebookExport}, resetsAt.AddDate(0, 0, -1)).
+				Count(&used).Error
+		}
+	}
+
+	return used, err
+}
+
+// exportQuotaReset returns the next midnight in the user's time zone, when the daily export quota resets.
+func exportQuotaReset(userID interface{}) (time.Time, error) {
+	user, err := FindUserByID(fmt.Sprint(userID))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	now := time.Now().In(userLocation(user))
+	return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location()), nil
+}
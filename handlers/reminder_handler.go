@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/models"
+	"noteapp-framework-backend/reminders"
+)
+
+// CreateReminder adds a reminder to a note. remind_at is an RFC 3339 time; an optional
+// rrule (e.g. FREQ=WEEKLY;BYDAY=MO) makes it recur in the user's time zone.
+func CreateReminder(c *gin.Context) {
+	var input struct {
+		RemindAt time.Time `json:"remind_at" binding:"required"`
+		RRule    string    `json:"rrule"`
+		Message  string    `json:"message"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var note models.Note
+	if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", c.Param("id")).First(&note).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found or access denied"})
+		return
+	}
+
+	user, err := FindUserByID(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	reminder := models.Reminder{
+		UserID:   user.ID,
+		NoteID:   uint(note.ID),
+		Message:  input.Message,
+		StartsAt: input.RemindAt,
+		RRule:    input.RRule,
+		Timezone: userLocation(user).String(),
+	}
+	if !scheduleReminder(c, &reminder) {
+		return
+	}
+
+	if err := config.DB.Create(&reminder).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reminder"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": reminder})
+}
+
+// GetReminders retrieves the reminders of the user, the next one to fire first.
+// Reminders without occurrences left are only included with ?all=true.
+func GetReminders(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	query := config.DB.Where("user_id = ?", userID)
+	if all, _ := strconv.ParseBool(c.Query("all")); !all {
+		query = query.Where("next_at IS NOT NULL")
+	}
+
+	var result []models.Reminder
+	if err := query.Order("next_at ASC NULLS LAST, id").Find(&result).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reminders"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// GetNoteReminders retrieves the reminders of the user on a note
+func GetNoteReminders(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var note models.Note
+	if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", noteIDParam(c)).First(&note).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found or access denied"})
+		return
+	}
+
+	var result []models.Reminder
+	if err := config.DB.Where("note_id = ? AND user_id = ?", note.ID, userID).Order("next_at ASC NULLS LAST, id").Find(&result).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reminders"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// UpdateReminder changes the time, recurrence or message of a reminder and reschedules it
+func UpdateReminder(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var reminder models.Reminder
+	if err := config.DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&reminder).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reminder not found or access denied"})
+		return
+	}
+
+	var input struct {
+		RemindAt *time.Time `json:"remind_at"`
+		RRule    *string    `json:"rrule"`
+		Message  *string    `json:"message"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.RemindAt != nil {
+		reminder.StartsAt = *input.RemindAt
+	}
+	if input.RRule != nil {
+		reminder.RRule = *input.RRule
+	}
+	if input.Message != nil {
+		reminder.Message = *input.Message
+	}
+	if !scheduleReminder(c, &reminder) {
+		return
+	}
+
+	if err := config.DB.Model(&reminder).Select("starts_at", "rrule", "message", "next_at").Updates(&reminder).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update reminder"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": reminder})
+}
+
+// DeleteReminder deletes a reminder by ID
+func DeleteReminder(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var reminder models.Reminder
+	if err := config.DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&reminder).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reminder not found or access denied"})
+		return
+	}
+
+	if err := config.DB.Delete(&reminder).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete reminder"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Reminder deleted successfully"})
+}
+
+// Private helper functions.
+
+// scheduleReminder computes the next occurrence of the reminder. It writes the error response
+// and returns false when the rule is invalid or the reminder would never fire.
+func scheduleReminder(c *gin.Context, reminder *models.Reminder) bool {
+	if err := reminders.Schedule(reminder, time.Now()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recurrence rule: " + err.Error()})
+		return false
+	}
+	if reminder.NextAt == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reminder has no occurrence in the future"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/models"
+)
+
+var (
+	// checklistPattern matches Markdown checklist items such as "- [ ] Write report" or "* [x] Done".
+	checklistPattern = regexp.MustCompile(`^(\s*[-*+]\s+\[)([ xX])(\]\s+)(.*)$`)
+	// dueDatePattern matches the due date syntax inside a checklist item: @due(2026-10-20).
+	dueDatePattern = regexp.MustCompile(`@due\((\d{4}-\d{2}-\d{2})\)`)
+)
+
+// parsedTask is a checklist item as found in the content of a note.
+type parsedTask struct {
+	Line    int
+	Text    string
+	Done    bool
+	DueDate *time.Time
+}
+
+// GetTasks retrieves the checklist items of the active workspace across all notebooks.
+// ?status=open|done|overdue filters them (overdue is open and due before today in the user's
+// time zone) and ?notebook_id= restricts them to one notebook.
+func GetTasks(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	query := config.DB.Model(&models.Task{}).Scopes(inWorkspace(c))
+
+	switch c.Query("status") {
+	case "":
+	case "open":
+		query = query.Where("done = ?", false)
+	case "done":
+		query = query.Where("done = ?", true)
+	case "overdue":
+		user, err := FindUserByID(userID.(string))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+			return
+		}
+		now := time.Now().In(userLocation(user))
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		query = query.Where("done = ? AND due_date < ?", false, today)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status must be open, done or overdue"})
+		return
+	}
+
+	if notebookID := c.Query("notebook_id"); notebookID != "" {
+		query = query.Where("note_id IN (?)", config.DB.Model(&models.Note{}).Select("id").Where("notebook_id = ?", notebookID))
+	}
+
+	var tasks []models.Task
+	if err := query.Order("due_date ASC NULLS LAST, note_id, line").Find(&tasks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tasks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": tasks})
+}
+
+// UpdateTask checks or unchecks a task by rewriting its line in the note content
+func UpdateTask(c *gin.Context) {
+	_, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var input struct {
+		Done *bool `json:"done" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var task models.Task
+	if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", c.Param("id")).First(&task).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found or access denied"})
+		return
+	}
+
+	var note models.Note
+	if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", task.NoteID).First(&note).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found or access denied"})
+		return
+	}
+
+	content, ok := setChecklistItem(note.Content, task, *input.Done)
+	if !ok {
+		c.JSON(http.StatusConflict, gin.H{"error": "The task no longer exists in the note"})
+		return
+	}
+
+	original := note
+	note.Content = content
+	if err := config.DB.Model(&note).Select("content").Updates(&note).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update note"})
+		return
+	}
+
+	afterNoteSaved(note, note.Title)
+	recordActivity(c, models.Activity{
+		Action:     models.ActivityNoteUpdate,
+		TargetType: "note",
+		TargetID:   uintPtr(uint(note.ID)),
+		NotebookID: uintPtr(note.NotebookID),
+	}, noteSummary(original), noteUpdateSummary(original, note))
+
+	if err := config.DB.Where("note_id = ? AND line = ?", note.ID, task.Line).First(&task).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch task"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": task})
+}
+
+// Private helper functions.
+
+// parseChecklist returns the checklist items in the content of a note.
+func parseChecklist(content string) []parsedTask {
+	var tasks []parsedTask
+
+	for i, line := range strings.Split(content, "\n") {
+		match := checklistPattern.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if match == nil {
+			continue
+		}
+
+		task := parsedTask{Line: i, Text: strings.TrimSpace(match[4]), Done: match[2] != " "}
+		if due := dueDatePattern.FindStringSubmatch(match[4]); due != nil {
+			if dueDate, err := time.Parse("2006-01-02", due[1]); err == nil {
+				task.DueDate = &dueDate
+			}
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks
+}
+
+// setChecklistItem rewrites the checkbox of the task in the content. If the lines of the note
+// moved since the task was stored, the item is looked up by its text instead.
+func setChecklistItem(content string, task models.Task, done bool) (string, bool) {
+	lines := strings.Split(content, "\n")
+
+	index := -1
+	if task.Line < len(lines) {
+		if match := checklistPattern.FindStringSubmatch(strings.TrimRight(lines[task.Line], "\r")); match != nil && strings.TrimSpace(match[4]) == task.Text {
+			index = task.Line
+		}
+	}
+	if index == -1 {
+		for i, line := range lines {
+			if match := checklistPattern.FindStringSubmatch(strings.TrimRight(line, "\r")); match != nil && strings.TrimSpace(match[4]) == task.Text {
+				index = i
+				break
+			}
+		}
+	}
+	if index == -1 {
+		return content, false
+	}
+
+	mark := " "
+	if done {
+		mark = "x"
+	}
+	suffix := ""
+	if strings.HasSuffix(lines[index], "\r") {
+		suffix = "\r"
+	}
+	match := checklistPattern.FindStringSubmatch(strings.TrimRight(lines[index], "\r"))
+	lines[index] = match[1] + mark + match[3] + match[4] + suffix
+
+	return strings.Join(lines, "\n"), true
+}
+
+// syncNoteTasks updates the tasks table to the checklist in the note's content. Existing rows
+// are reused in order, so task IDs stay stable as long as items are not reordered.
+func syncNoteTasks(note models.Note) error {
+	var existing []models.Task
+	if err := config.DB.Where("note_id = ?", note.ID).Order("line").Find(&existing).Error; err != nil {
+		return err
+	}
+
+	parsed := parseChecklist(note.Content)
+	for i, item := range parsed {
+		task := models.Task{UserID: note.UserID, NoteID: uint(note.ID)}
+		if i < len(existing) {
+			task = existing[i]
+		}
+		task.Line, task.Text, task.Done, task.DueDate = item.Line, item.Text, item.Done, item.DueDate
+
+		if err := config.DB.Save(&task).Error; err != nil {
+			return err
+		}
+	}
+
+	if len(existing) > len(parsed) {
+		var stale []int
+		for _, task := range existing[len(parsed):] {
+			stale = append(stale, task.ID)
+		}
+		if err := config.DB.Delete(&models.Task{}, stale).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// afterNoteSaved keeps the data derived from a note's content up to date: its links and its tasks.
+// End-to-end encrypted notes have no content the server could derive anything from, and
+// locked notes keep none of it, as it would reveal their content.
+func afterNoteSaved(note models.Note, previousTitle string) {
+	if note.KeyVersion > 0 {
+		return
+	}
+	if note.Locked {
+		note.Content = ""
+	}
+
+	updateNoteLinks(note, previousTitle)
+
+	if err := syncNoteTasks(note); err != nil {
+		log.Printf("Failed to sync tasks of note %d: %v", note.ID, err)
+	}
+}
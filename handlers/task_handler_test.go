@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"noteapp-framework-backend/models"
+)
+
+func TestParseChecklist(t *testing.T) {
+	content := "# Plan\n- [ ] Write report @due(2026-10-20)\n  * [x] Book room\nNot a task [ ]\n- [] Malformed"
+
+	tasks := parseChecklist(content)
+	if assert.Len(t, tasks, 2) {
+		assert.Equal(t, 1, tasks[0].Line)
+		assert.Equal(t, "Write report @due(2026-10-20)", tasks[0].Text)
+		assert.False(t, tasks[0].Done)
+		if assert.NotNil(t, tasks[0].DueDate) {
+			assert.Equal(t, time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC), *tasks[0].DueDate)
+		}
+
+		assert.Equal(t, 2, tasks[1].Line)
+		assert.True(t, tasks[1].Done)
+		assert.Nil(t, tasks[1].DueDate)
+	}
+}
+
+func TestSetChecklistItem(t *testing.T) {
+	content := "Intro\n- [ ] Write report\n- [x] Book room"
+
+	updated, ok := setChecklistItem(content, models.Task{Line: 1, Text: "Write report"}, true)
+	assert.True(t, ok)
+	assert.Equal(t, "Intro\n- [x] Write report\n- [x] Book room", updated)
+
+	// The stored line is stale, so the item is found by its text
+	updated, ok = setChecklistItem("- [x] Book room\r\nIntro", models.Task{Line: 2, Text: "Book room"}, false)
+	assert.True(t, ok)
+	assert.Equal(t, "- [ ] Book room\r\nIntro", updated)
+
+	_, ok = setChecklistItem(content, models.Task{Line: 1, Text: "Removed"}, true)
+	assert.False(t, ok)
+}
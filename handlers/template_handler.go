@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/models"
+)
+
+// placeholderPattern matches {{date}}, {{time}}, {{datetime}}, {{user}}, {{notebook}}
+// and custom prompts written as {{prompt:Attendees}}.
+var placeholderPattern = regexp.MustCompile(`\{\{\s*(date|time|datetime|user|notebook|prompt:([^{}]+?))\s*\}\}`)
+
+// templateValues are the values available to the placeholders when a template is rendered.
+type templateValues struct {
+	Now      time.Time
+	User     string
+	Notebook string
+	Prompts  map[string]string
+}
+
+// CreateTemplate creates a new template, optionally shared within a notebook
+func CreateTemplate(c *gin.Context) {
+	var input struct {
+		Name       string `json:"name" binding:"required"`
+		Title      string `json:"title" binding:"required"`
+		Content    string `json:"content"`
+		NotebookID *uint  `json:"notebook_id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+	userIDUint, err := strconv.ParseUint(userID.(string), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	if input.NotebookID != nil && !notebookInWorkspace(config.DB, c.GetUint("workspace_id"), *input.NotebookID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notebook not found or access denied"})
+		return
+	}
+
+	template := models.Template{
+		UserID:     uint(userIDUint),
+		NotebookID: input.NotebookID,
+		Name:       input.Name,
+		Title:      input.Title,
+		Content:    input.Content,
+	}
+	if err := config.DB.Create(&template).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create template"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": template, "prompts": templatePrompts(template)})
+}
+
+// GetTemplates retrieves the templates of the user. With ?notebook_id= only the templates
+// usable in that notebook are returned: the ones shared in it and the global ones.
+func GetTemplates(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	query := config.DB.Where("user_id = ?", userID)
+	if notebookID := c.Query("notebook_id"); notebookID != "" {
+		query = query.Where("notebook_id IS NULL OR notebook_id = ?", notebookID)
+	}
+
+	var templates []models.Template
+	if err := query.Order("name").Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch templates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": templates})
+}
+
+// GetTemplate retrieves a single template with the custom prompts it asks for
+func GetTemplate(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var template models.Template
+	if err := config.DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&template).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found or access denied"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": template, "prompts": templatePrompts(template)})
+}
+
+// UpdateTemplate updates an existing template
+func UpdateTemplate(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var template models.Template
+	if err := config.DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&template).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found or access denied"})
+		return
+	}
+
+	var input struct {
+		Name       *string `json:"name"`
+		Title      *string `json:"title"`
+		Content    *string `json:"content"`
+		NotebookID *uint   `json:"notebook_id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Name != nil {
+		template.Name = *input.Name
+	}
+	if input.Title != nil {
+		template.Title = *input.Title
+	}
+	if input.Content != nil {
+		template.Content = *input.Content
+	}
+	if input.NotebookID != nil {
+		if !notebookInWorkspace(config.DB, c.GetUint("workspace_id"), *input.NotebookID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Notebook not found or access denied"})
+			return
+		}
+		template.NotebookID = input.NotebookID
+	}
+
+	if err := config.DB.Save(&template).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update template"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": template, "prompts": templatePrompts(template)})
+}
+
+// DeleteTemplate deletes a template by ID
+func DeleteTemplate(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var template models.Template
+	if err := config.DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&template).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found or access denied"})
+		return
+	}
+
+	if err := config.DB.Delete(&template).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete template"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Template deleted successfully"})
+}
+
+// CreateNoteFromTemplate renders a template into a new note of the target notebook.
+// Values for the custom prompts are passed in "prompts".
+func CreateNoteFromTemplate(c *gin.Context) {
+	var input struct {
+		NotebookID uint              `json:"notebook_id" binding:"required"`
+		Title      string            `json:"title"`
+		Prompts    map[string]string `json:"prompts"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var template models.Template
+	if err := config.DB.Where("id = ? AND user_id = ?", c.Param("templateId"), userID).First(&template).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found or access denied"})
+		return
+	}
+	if template.NotebookID != nil && *template.NotebookID != input.NotebookID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Template is only available in its own notebook"})
+		return
+	}
+
+	var notebook models.Notebook
+	if err := config.DB.Scopes(inWorkspace(c)).Where("id = ?", input.NotebookID).First(&notebook).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notebook not found or access denied"})
+		return
+	}
+	if rejectEncrypted(c, notebook) {
+		return
+	}
+
+	user, err := FindUserByID(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	values := templateValues{Now: time.Now().In(userLocation(user)), User: user.Username, Notebook: notebook.Name, Prompts: input.Prompts}
+	// An explicit title replaces the template's title, including its prompts
+	titleTemplate := template.Title
+	if input.Title != "" {
+		titleTemplate = input.Title
+	}
+
+	title, missing := renderTemplate(titleTemplate, values)
+	content, missingInContent := renderTemplate(template.Content, values)
+	for _, prompt := range missingInContent {
+		if !slices.Contains(missing, prompt) {
+			missing = append(missing, prompt)
+		}
+	}
+	if len(missing) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing values for prompts", "missing": missing})
+		return
+	}
+
+	note := models.Note{Title: title, Content: content, NotebookID: input.NotebookID, UserID: user.ID}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if !enforceQuota(c, tx, userID, quotaNotes, 1) {
+			return errQuotaExceeded
+		}
+		return tx.Create(&note).Error
+	})
+	if errors.Is(err, errQuotaExceeded) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create note"})
+		return
+	}
+
+	afterNoteCreated(c, note)
+
+	c.JSON(http.StatusCreated, gin.H{"data": note})
+}
+
+// Private helper functions.
+
+// renderTemplate replaces the placeholders in text. It returns the prompts that had no value.
+func renderTemplate(text string, values templateValues) (string, []string) {
+	var missing []string
+	seenMissing := make(map[string]bool)
+
+	rendered := placeholderPattern.ReplaceAllStringFunc(text, func(match string) string {
+		parts := placeholderPattern.FindStringSubmatch(match)
+		switch parts[1] {
+		case "date":
+			return values.Now.Format("2006-01-02")
+		case "time":
+			return values.Now.Format("15:04")
+		case "datetime":
+			return values.Now.Format("2006-01-02 15:04")
+		case "user":
+			return values.User
+		case "notebook":
+			return values.Notebook
+		}
+
+		prompt := strings.TrimSpace(parts[2])
+		value, ok := values.Prompts[prompt]
+		if !ok && !seenMissing[prompt] {
+			seenMissing[prompt] = true
+			missing = append(missing, prompt)
+		}
+		return value
+	})
+
+	return rendered, missing
+}
+
+// templatePrompts lists the custom prompts of a template, each one once.
+func templatePrompts(template models.Template) []string {
+	prompts := []string{}
+	seen := make(map[string]bool)
+
+	for _, match := range placeholderPattern.FindAllStringSubmatch(template.Title+"\n"+template.Content, -1) {
+		prompt := strings.TrimSpace(match[2])
+		if prompt == "" || seen[prompt] {
+			continue
+		}
+		seen[prompt] = true
+		prompts = append(prompts, prompt)
+	}
+
+	return prompts
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"noteapp-framework-backend/models"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	values := templateValues{
+		Now:      time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC),
+		User:     "alice",
+		Notebook: "Meetings",
+		Prompts:  map[string]string{"Attendees": "Bob, Carol"},
+	}
+
+	rendered, missing := renderTemplate("{{date}} {{ time }} by {{user}} in {{notebook}}: {{prompt:Attendees}}", values)
+	assert.Equal(t, "2026-10-19 09:30 by alice in Meetings: Bob, Carol", rendered)
+	assert.Empty(t, missing)
+
+	_, missing = renderTemplate("{{prompt:Agenda}} {{prompt: Agenda }} {{prompt:Attendees}}", values)
+	assert.Equal(t, []string{"Agenda"}, missing)
+}
+
+func TestTemplatePrompts(t *testing.T) {
+	template := models.Template{Title: "{{date}} {{prompt:Topic}}", Content: "{{prompt:Attendees}}\n{{prompt:Topic}}"}
+
+	assert.Equal(t, []string{"Topic", "Attendees"}, templatePrompts(template))
+}
@@ -0,0 +1,488 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/models"
+)
+
+var (
+	errLastWorkspaceAdmin = errors.New("A workspace needs at least one admin")
+	errPersonalWorkspace  = errors.New("Personal workspaces cannot be shared")
+	errSoleKeyHolder      = errors.New("The member is the only one holding the key of an encrypted notebook, delete it first")
+)
+
+// workspaceWithRole is a workspace as listed for one of its members.
+type workspaceWithRole struct {
+	models.Workspace
+	Role string `json:"role"`
+}
+
+// workspaceMember is a member as listed in the members of a workspace.
+type workspaceMember struct {
+	UserID   uint   `json:"user_id"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+// CreateWorkspace creates a workspace with the user as its admin
+func CreateWorkspace(c *gin.Context) {
+	var input struct {
+		Name string `json:"name" binding:"required,max=255"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+	userIDUint, err := strconv.ParseUint(userID.(string), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	workspace := models.Workspace{Name: input.Name}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&workspace).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.WorkspaceMember{WorkspaceID: workspace.ID, UserID: uint(userIDUint), Role: models.WorkspaceRoleAdmin}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create workspace"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": workspaceWithRole{Workspace: workspace, Role: models.WorkspaceRoleAdmin}})
+}
+
+// GetWorkspaces retrieves the workspaces the user is a member of, personal workspace first
+func GetWorkspaces(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var workspaces []workspaceWithRole
+	if err := config.DB.Model(&models.Workspace{}).
+		Select("workspaces.*, workspace_members.role").
+		Joins("JOIN workspace_members ON workspace_members.workspace_id = workspaces.id").
+		Where("workspace_members.user_id = ?", userID).
+		Order("workspaces.personal_user_id IS NULL, workspaces.name, workspaces.id").
+		Scan(&workspaces).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch workspaces"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": workspaces})
+}
+
+// UpdateWorkspace renames a workspace. Only admins can rename it.
+func UpdateWorkspace(c *gin.Context) {
+	var input struct {
+		Name string `json:"name" binding:"required,max=255"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	workspace, ok := adminWorkspace(c)
+	if !ok {
+		return
+	}
+
+	workspace.Name = input.Name
+	if err := config.DB.Model(&workspace).Update("name", workspace.Name).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update workspace"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": workspace})
+}
+
+// GetWorkspaceMembers lists the members of a workspace with their role
+func GetWorkspaceMembers(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	workspaceID := c.Param("id")
+	if !isWorkspaceMember(workspaceID, userID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Workspace not found or access denied"})
+		return
+	}
+
+	var members []workspaceMember
+	if err := config.DB.Model(&models.WorkspaceMember{}).
+		Select("workspace_members.user_id, users.username, workspace_members.role").
+		Joins("JOIN users ON users.id = workspace_members.user_id").
+		Where("workspace_members.workspace_id = ?", workspaceID).
+		Order("users.username").
+		Scan(&members).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch members"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": members})
+}
+
+// UpdateWorkspaceMember changes the role of a member. Only admins can change roles, and the
+// last admin cannot be demoted.
+func UpdateWorkspaceMember(c *gin.Context) {
+	var input struct {
+		Role string `json:"role" binding:"required,oneof=admin member"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	workspace, ok := adminWorkspace(c)
+	if !ok {
+		return
+	}
+
+	var member models.WorkspaceMember
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("workspace_id = ? AND user_id = ?", workspace.ID, c.Param("userId")).First(&member).Error; err != nil {
+			return err
+		}
+		if member.Role == models.WorkspaceRoleAdmin && input.Role != models.WorkspaceRoleAdmin {
+			if err := checkOtherAdmins(tx, member); err != nil {
+				return err
+			}
+		}
+		member.Role = input.Role
+		return tx.Model(&member).Update("role", member.Role).Error
+	})
+	if !workspaceMemberChanged(c, err, "Failed to update member") {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": member})
+}
+
+// RemoveWorkspaceMember removes a member from a workspace. Admins can remove anyone, members
+// can only leave. The last admin cannot leave, which keeps personal workspaces with their
+// owner. The removed member loses their keys of the encrypted notebooks of the workspace and
+// their reminders on its notes. As they may have kept the keys, notes of those notebooks cannot
+// be written until their creator rotates the key. The encrypted notebooks the member created pass
+// to another holder of their key, so that someone can still rotate it.
+func RemoveWorkspaceMember(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var workspace models.Workspace
+	if err := config.DB.Where("id = ?", c.Param("id")).First(&workspace).Error; err != nil || !isWorkspaceMember(workspace.ID, userID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Workspace not found or access denied"})
+		return
+	}
+	if c.Param("userId") != userID.(string) && !isWorkspaceAdmin(workspace.ID, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only workspace admins can remove other members"})
+		return
+	}
+
+	var member models.WorkspaceMember
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("workspace_id = ? AND user_id = ?", workspace.ID, c.Param("userId")).First(&member).Error; err != nil {
+			return err
+		}
+		if member.Role == models.WorkspaceRoleAdmin {
+			if err := checkOtherAdmins(tx, member); err != nil {
+				return err
+			}
+		}
+		if err := transferEncryptedNotebooks(tx, workspace.ID, member.UserID); err != nil {
+			return err
+		}
+		keyed := tx.Model(&models.NotebookKey{}).Select("notebook_id").Where("user_id = ?", member.UserID)
+		if err := tx.Model(&models.Notebook{}).Scopes(workspaceScope(workspace.ID)).
+			Where("encrypted = ? AND id IN (?)", true, keyed).
+			Update("rotation_required", true).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ? AND notebook_id IN (?)", member.UserID,
+			tx.Model(&models.Notebook{}).Scopes(workspaceScope(workspace.ID)).Select("id")).
+			Delete(&models.NotebookKey{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ? AND note_id IN (?)", member.UserID,
+			tx.Model(&models.Note{}).Scopes(workspaceScope(workspace.ID)).Select("id")).
+			Delete(&models.Reminder{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&member).Error
+	})
+	if !workspaceMemberChanged(c, err, "Failed to remove member") {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
+
+// InviteToWorkspace invites a user, by username, to join a workspace with the given role
+// (member by default). Only admins can invite, and personal workspaces cannot be shared.
+func InviteToWorkspace(c *gin.Context) {
+	var input struct {
+		Username string `json:"username" binding:"required"`
+		Role     string `json:"role" binding:"omitempty,oneof=admin member"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Role == "" {
+		input.Role = models.WorkspaceRoleMember
+	}
+
+	workspace, ok := adminWorkspace(c)
+	if !ok {
+		return
+	}
+	if workspace.PersonalUserID != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errPersonalWorkspace.Error()})
+		return
+	}
+
+	var invitee models.User
+	if err := config.DB.Where("username = ?", input.Username).First(&invitee).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if isWorkspaceMember(workspace.ID, invitee.ID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is already a member of the workspace"})
+		return
+	}
+
+	var pending int64
+	config.DB.Model(&models.WorkspaceInvitation{}).Where("workspace_id = ? AND user_id = ?", workspace.ID, invitee.ID).Count(&pending)
+	if pending > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is already invited to the workspace"})
+		return
+	}
+
+	userIDUint, err := strconv.ParseUint(c.GetString("user_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+	invitation := models.WorkspaceInvitation{WorkspaceID: workspace.ID, UserID: invitee.ID, InvitedBy: uintPtr(uint(userIDUint)), Role: input.Role}
+	if err := config.DB.Create(&invitation).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": invitation})
+}
+
+// GetWorkspaceInvitations lists the pending invitations of a workspace. Only admins see them.
+func GetWorkspaceInvitations(c *gin.Context) {
+	workspace, ok := adminWorkspace(c)
+	if !ok {
+		return
+	}
+
+	var invitations []models.WorkspaceInvitation
+	if err := config.DB.Where("workspace_id = ?", workspace.ID).Order("id").Find(&invitations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invitations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": invitations})
+}
+
+// GetMyInvitations lists the pending invitations of the user, with the name of the workspace
+func GetMyInvitations(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var invitations []struct {
+		models.WorkspaceInvitation
+		WorkspaceName string `json:"workspace_name"`
+	}
+	if err := config.DB.Model(&models.WorkspaceInvitation{}).
+		Select("workspace_invitations.*, workspaces.name AS workspace_name").
+		Joins("JOIN workspaces ON workspaces.id = workspace_invitations.workspace_id").
+		Where("workspace_invitations.user_id = ?", userID).
+		Order("workspace_invitations.id").
+		Scan(&invitations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invitations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": invitations})
+}
+
+// AcceptInvitation makes the user a member of the workspace they were invited to
+func AcceptInvitation(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var invitation models.WorkspaceInvitation
+	if err := config.DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&invitation).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	}
+
+	member := models.WorkspaceMember{WorkspaceID: invitation.WorkspaceID, UserID: invitation.UserID, Role: invitation.Role}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&invitation).Error; err != nil {
+			return err
+		}
+		return tx.Create(&member).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invitation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": member})
+}
+
+// DeleteInvitation declines an invitation, or revokes it when called by a workspace admin
+func DeleteInvitation(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	adminOf := config.DB.Model(&models.WorkspaceMember{}).Select("workspace_id").Where("user_id = ? AND role = ?", userID, models.WorkspaceRoleAdmin)
+	var invitation models.WorkspaceInvitation
+	if err := config.DB.Where("id = ? AND (user_id = ? OR workspace_id IN (?))", c.Param("id"), userID, adminOf).First(&invitation).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	}
+
+	if err := config.DB.Delete(&invitation).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete invitation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation deleted successfully"})
+}
+
+// Private helper functions.
+
+// createPersonalWorkspace creates the personal workspace of a new user.
+func createPersonalWorkspace(tx *gorm.DB, userID uint) error {
+	workspace := models.Workspace{Name: "Personal", PersonalUserID: &userID}
+	if err := tx.Create(&workspace).Error; err != nil {
+		return err
+	}
+	return tx.Create(&models.WorkspaceMember{WorkspaceID: workspace.ID, UserID: userID, Role: models.WorkspaceRoleAdmin}).Error
+}
+
+// adminWorkspace loads the workspace of the :id parameter if the user is one of its admins.
+func adminWorkspace(c *gin.Context) (models.Workspace, bool) {
+	var workspace models.Workspace
+	if err := config.DB.Where("id = ?", c.Param("id")).First(&workspace).Error; err != nil || !isWorkspaceMember(workspace.ID, c.GetString("user_id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Workspace not found or access denied"})
+		return workspace, false
+	}
+	if !isWorkspaceAdmin(workspace.ID, c.GetString("user_id")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only workspace admins can do this"})
+		return workspace, false
+	}
+	return workspace, true
+}
+
+func isWorkspaceMember(workspaceID interface{}, userID interface{}) bool {
+	var count int64
+	config.DB.Model(&models.WorkspaceMember{}).Where("workspace_id = ? AND user_id = ?", workspaceID, userID).Count(&count)
+	return count > 0
+}
+
+func isWorkspaceAdmin(workspaceID interface{}, userID interface{}) bool {
+	var count int64
+	config.DB.Model(&models.WorkspaceMember{}).
+		Where("workspace_id = ? AND user_id = ? AND role = ?", workspaceID, userID, models.WorkspaceRoleAdmin).
+		Count(&count)
+	return count > 0
+}
+
+// checkOtherAdmins fails when the member is the only admin of their workspace. The admins are
+// locked, so concurrent demotions cannot leave the workspace without one.
+func checkOtherAdmins(tx *gorm.DB, member models.WorkspaceMember) error {
+	var admins []models.WorkspaceMember
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("workspace_id = ? AND role = ?", member.WorkspaceID, models.WorkspaceRoleAdmin).
+		Find(&admins).Error; err != nil {
+		return err
+	}
+	for _, admin := range admins {
+		if admin.UserID != member.UserID {
+			return nil
+		}
+	}
+	return errLastWorkspaceAdmin
+}
+
+// transferEncryptedNotebooks makes another holder of the current key the creator of each
+// encrypted notebook the user created in the workspace, the one who got it first. It fails with
+// errSoleKeyHolder when nobody else holds the key.
+func transferEncryptedNotebooks(tx *gorm.DB, workspaceID, userID uint) error {
+	var notebooks []models.Notebook
+	if err := tx.Scopes(workspaceScope(workspaceID)).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND encrypted = ?", userID, true).
+		Find(&notebooks).Error; err != nil {
+		return err
+	}
+
+	for _, notebook := range notebooks {
+		var key models.NotebookKey
+		if err := tx.Where("notebook_id = ? AND key_version = ? AND user_id <> ?", notebook.ID, notebook.KeyVersion, userID).
+			Order("created_at, user_id").Limit(1).Find(&key).Error; err != nil {
+			return err
+		}
+		if key.UserID == 0 {
+			return errSoleKeyHolder
+		}
+		if err := tx.Model(&notebook).Update("user_id", key.UserID).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// workspaceMemberChanged writes the error response of a member change, if any, and reports
+// whether the change succeeded.
+func workspaceMemberChanged(c *gin.Context, err error, message string) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+	case errors.Is(err, errLastWorkspaceAdmin), errors.Is(err, errSoleKeyHolder):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+	return false
+}
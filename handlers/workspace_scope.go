@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/models"
+)
+
+var errNoWorkspaceScope = errors.New("no workspace scope for model")
+
+// workspaceNoteIDs selects the IDs of the notes in the notebooks of a workspace.
+const workspaceNoteIDs = "SELECT notes.id FROM notes JOIN notebooks ON notebooks.id = notes.notebook_id WHERE notebooks.workspace_id = ?"
+
+// memberNoteIDs selects the IDs of the notes in every workspace a user is a member of, for
+// queries across workspaces.
+const memberNoteIDs = "SELECT notes.id FROM notes JOIN notebooks ON notebooks.id = notes.notebook_id " +
+	"WHERE notebooks.workspace_id IN (SELECT workspace_id FROM workspace_members WHERE user_id = ?)"
+
+// workspaceConditions tells how the rows of each tenant table belong to a workspace.
+// Conditions are qualified with the table, so they hold in joins as well.
+var workspaceConditions = map[reflect.Type]string{
+	reflect.TypeOf(models.Notebook{}):   "notebooks.workspace_id = ?",
+	reflect.TypeOf(models.Note{}):       "notes.notebook_id IN (SELECT id FROM notebooks WHERE workspace_id = ?)",
+	reflect.TypeOf(models.Attachment{}): "attachments.note_id IN (" + workspaceNoteIDs + ")",
+	reflect.TypeOf(models.Task{}):       "tasks.note_id IN (" + workspaceNoteIDs + ")",
+	reflect.TypeOf(models.NoteLink{}):   "note_links.source_note_id IN (" + workspaceNoteIDs + ")",
+}
+
+// workspaceScope restricts a query on notebooks, notes, attachments, tasks or note links to
+// one workspace. It is the tenant isolation of these tables, so every query on them goes
+// through it. Queries on any other model fail instead of silently running unscoped.
+func workspaceScope(workspaceID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		// Scopes run before the statement's schema is parsed, so look at the Go type
+		model := db.Statement.Model
+		if model == nil {
+			model = db.Statement.Dest
+		}
+		condition, ok := workspaceConditions[indirectType(reflect.TypeOf(model))]
+		if !ok {
+			db.AddError(fmt.Errorf("%w %T", errNoWorkspaceScope, model))
+			return db
+		}
+		return db.Where(condition, workspaceID)
+	}
+}
+
+// inWorkspace scopes a query to the active workspace of the request, see
+// middleware.WorkspaceMiddleware. Without one the query matches nothing.
+func inWorkspace(c *gin.Context) func(*gorm.DB) *gorm.DB {
+	return workspaceScope(c.GetUint("workspace_id"))
+}
+
+// Private helper functions.
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t != nil && (t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		t = t.Elem()
+	}
+	return t
+}
+
+func notebookInWorkspace(db *gorm.DB, workspaceID uint, notebookID uint) bool {
+	var count int64
+	db.Model(&models.Notebook{}).Scopes(workspaceScope(workspaceID)).Where("id = ?", notebookID).Count(&count)
+	return count > 0
+}
+
+// notebookWorkspace returns the workspace a notebook belongs to, 0 if it does not exist.
+func notebookWorkspace(notebookID uint) uint {
+	var workspaceIDs []uint
+	config.DB.Model(&models.Notebook{}).Where("id = ?", notebookID).Limit(1).Pluck("workspace_id", &workspaceIDs)
+	if len(workspaceIDs) == 0 {
+		return 0
+	}
+	return workspaceIDs[0]
+}
+
+// personalWorkspace returns the ID of the personal workspace of a user, 0 if it does not exist.
+func personalWorkspace(userID interface{}) uint {
+	var workspace models.Workspace
+	if err := config.DB.Select("id").Where("personal_user_id = ?", userID).First(&workspace).Error; err != nil {
+		return 0
+	}
+	return workspace.ID
+}
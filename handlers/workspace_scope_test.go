@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"noteapp-framework-backend/models"
+)
+
+// dryRunDB builds SQL without a database connection.
+func dryRunDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	require.NoError(t, err)
+	return db
+}
+
+func TestWorkspaceScope(t *testing.T) {
+	db := dryRunDB(t)
+
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var notebooks []models.Notebook
+		return tx.Scopes(workspaceScope(7)).Where("archived = ?", false).Find(&notebooks)
+	})
+	assert.Contains(t, sql, "notebooks.workspace_id = 7")
+
+	sql = db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var note models.Note
+		return tx.Scopes(workspaceScope(7)).Where("id = ?", 1).First(&note)
+	})
+	assert.Contains(t, sql, "notes.notebook_id IN (SELECT id FROM notebooks WHERE workspace_id = 7)")
+
+	sql = db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var notes []models.Note
+		return tx.Scopes(workspaceScope(7), inActiveNotebook).Where("favorite = ?", true).Find(&notes)
+	})
+	assert.Contains(t, sql, "notes.notebook_id NOT IN (SELECT id FROM notebooks WHERE archived = true)")
+
+	// The model decides, not the destination
+	sql = db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var titles []struct{ Title string }
+		return tx.Model(&models.Task{}).Scopes(workspaceScope(7)).Find(&titles)
+	})
+	assert.Contains(t, sql, "tasks.note_id IN (SELECT notes.id FROM notes JOIN notebooks ON notebooks.id = notes.notebook_id WHERE notebooks.workspace_id = 7)")
+
+	// Tables without a workspace scope are refused instead of being queried unscoped
+	var templates []models.Template
+	err := db.Scopes(workspaceScope(7)).Find(&templates).Error
+	assert.ErrorIs(t, err, errNoWorkspaceScope)
+}
@@ -0,0 +1,220 @@
+package images
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // Register the GIF decoder
+	"image/jpeg"
+	_ "image/png" // Register the PNG decoder
+
+	"github.com/HugoSmits86/nativewebp"
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // Register the WebP decoder
+)
+
+// MaxPixels bounds the images that are decoded, so a small file cannot expand into gigabytes.
+const MaxPixels = 50_000_000
+
+const jpegQuality = 85
+
+// ErrTooLarge is returned for images with more than MaxPixels pixels.
+var ErrTooLarge = errors.New("image has too many pixels")
+
+// Variant is a resized copy of an image generated on upload.
+type Variant struct {
+	Name    string
+	MaxSize int // Longest side in pixels; smaller images are not upscaled
+	Format  string
+}
+
+// Variants lists the copies generated for every uploaded image. WebP copies are lossless.
+var Variants = []Variant{
+	{Name: "thumb", MaxSize: 320, Format: "jpeg"},
+	{Name: "thumb", MaxSize: 320, Format: "webp"},
+	{Name: "medium", MaxSize: 1280, Format: "jpeg"},
+	{Name: "medium", MaxSize: 1280, Format: "webp"},
+}
+
+var supportedTypes = map[string]bool{"image/jpeg": true, "image/png": true, "image/gif": true, "image/webp": true}
+
+// IsImage reports whether images of the content type can be processed.
+func IsImage(contentType string) bool {
+	return supportedTypes[contentType]
+}
+
+// File is the name a variant is stored and served under, such as "thumb.webp".
+func (v Variant) File() string {
+	if v.Format == "webp" {
+		return v.Name + ".webp"
+	}
+	return v.Name + ".jpg"
+}
+
+// ContentType is the MIME type of the variant.
+func (v Variant) ContentType() string {
+	return "image/" + v.Format
+}
+
+// FindVariant returns the variant stored under the file name.
+func FindVariant(file string) (Variant, bool) {
+	for _, variant := range Variants {
+		if variant.File() == file {
+			return variant, true
+		}
+	}
+	return Variant{}, false
+}
+
+// Sanitize removes the EXIF, XMP and similar metadata of an image, which may hold the GPS
+// position or the camera of the author. Pixels are kept as they are, except for JPEG photos
+// with an EXIF orientation: these are rotated and re-encoded so they still display upright.
+func Sanitize(data []byte, contentType string) (clean []byte, width, height int, err error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, 0, 0, ErrTooLarge
+	}
+
+	switch contentType {
+	case "image/jpeg":
+		stripped, orientation, err := stripJPEG(data)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		if orientation == 1 {
+			return stripped, config.Width, config.Height, nil
+		}
+
+		img, err := jpeg.Decode(bytes.NewReader(stripped))
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		img = orient(img, orientation)
+		var out bytes.Buffer
+		if err := jpeg.Encode(&out, img, &jpeg.Options{Quality: 92}); err != nil {
+			return nil, 0, 0, err
+		}
+		return out.Bytes(), img.Bounds().Dx(), img.Bounds().Dy(), nil
+	case "image/png":
+		clean, err = stripPNG(data)
+	case "image/webp":
+		clean, err = stripWebP(data)
+	case "image/gif":
+		clean = data
+	default:
+		return nil, 0, 0, fmt.Errorf("unsupported image type %q", contentType)
+	}
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return clean, config.Width, config.Height, nil
+}
+
+// Render decodes an image and encodes each of the variants, keyed by their File name.
+func Render(data []byte, variants []Variant) (map[string][]byte, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	rendered := make(map[string][]byte, len(variants))
+	for _, variant := range variants {
+		resized := resize(img, variant.MaxSize)
+
+		var out bytes.Buffer
+		switch variant.Format {
+		case "webp":
+			err = nativewebp.Encode(&out, resized, nil)
+		default:
+			err = jpeg.Encode(&out, flatten(resized), &jpeg.Options{Quality: jpegQuality})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("encode %s: %w", variant.File(), err)
+		}
+		rendered[variant.File()] = out.Bytes()
+	}
+
+	return rendered, nil
+}
+
+// resize scales the image down so its longest side is at most maxSize pixels.
+func resize(img image.Image, maxSize int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxSize && height <= maxSize {
+		return img
+	}
+
+	if width >= height {
+		height = max(1, height*maxSize/width)
+		width = maxSize
+	} else {
+		width = max(1, width*maxSize/height)
+		height = maxSize
+	}
+
+	resized := image.NewNRGBA(image.Rect(0, 0, width, height))
+	xdraw.CatmullRom.Scale(resized, resized.Bounds(), img, bounds, xdraw.Src, nil)
+	return resized
+}
+
+// flatten draws the image on a white background, since JPEG has no transparency.
+func flatten(img image.Image) image.Image {
+	flat := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+	return flat
+}
+
+// orient applies an EXIF orientation (2-8) so the image displays as intended without it.
+func orient(img image.Image, orientation int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	outWidth, outHeight := width, height
+	if orientation >= 5 {
+		outWidth, outHeight = height, width
+	}
+	out := image.NewNRGBA(image.Rect(0, 0, outWidth, outHeight))
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = width-1-x, y
+			case 3:
+				dx, dy = width-1-x, height-1-y
+			case 4:
+				dx, dy = x, height-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = height-1-y, x
+			case 7:
+				dx, dy = height-1-y, width-1-x
+			case 8:
+				dx, dy = y, width-1-x
+			default:
+				dx, dy = x, y
+			}
+			out.Set(dx, dy, img.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+
+	return out
+}
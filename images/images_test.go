@@ -0,0 +1,105 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testImage(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+	return img
+}
+
+// exifSegment builds an APP1 segment holding a little-endian EXIF block with an orientation.
+func exifSegment(orientation uint16) []byte {
+	tiff := []byte("II*\x00\x08\x00\x00\x00")
+	tiff = binary.LittleEndian.AppendUint16(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 3)
+	tiff = binary.LittleEndian.AppendUint32(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xFF, 0xE1}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+func TestSanitizeJPEG(t *testing.T) {
+	var encoded bytes.Buffer
+	require.NoError(t, jpeg.Encode(&encoded, testImage(40, 20), nil))
+	plain := encoded.Bytes()
+
+	// An EXIF block without rotation is dropped without touching the image data
+	withExif := append(append(append([]byte{}, plain[:2]...), exifSegment(1)...), plain[2:]...)
+	clean, width, height, err := Sanitize(withExif, "image/jpeg")
+	require.NoError(t, err)
+	assert.Equal(t, plain, clean)
+	assert.Equal(t, 40, width)
+	assert.Equal(t, 20, height)
+
+	// Rotated photos are turned upright
+	rotated := append(append(append([]byte{}, plain[:2]...), exifSegment(6)...), plain[2:]...)
+	clean, width, height, err = Sanitize(rotated, "image/jpeg")
+	require.NoError(t, err)
+	assert.NotContains(t, string(clean), "Exif")
+	assert.Equal(t, 20, width)
+	assert.Equal(t, 40, height)
+}
+
+func TestSanitizePNG(t *testing.T) {
+	var encoded bytes.Buffer
+	require.NoError(t, png.Encode(&encoded, testImage(10, 10)))
+	plain := encoded.Bytes()
+
+	text := []byte("tEXtAuthor\x00Bob")
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(text)-4))
+	chunk = append(append(chunk, text...), 0, 0, 0, 0)
+	withText := append(append(append([]byte{}, plain[:33]...), chunk...), plain[33:]...)
+
+	clean, _, _, err := Sanitize(withText, "image/png")
+	require.NoError(t, err)
+	assert.Equal(t, plain, clean)
+}
+
+func TestRender(t *testing.T) {
+	var encoded bytes.Buffer
+	require.NoError(t, png.Encode(&encoded, testImage(640, 200)))
+
+	rendered, err := Render(encoded.Bytes(), Variants)
+	require.NoError(t, err)
+	assert.Len(t, rendered, len(Variants))
+
+	thumb, format, err := image.Decode(bytes.NewReader(rendered["thumb.jpg"]))
+	require.NoError(t, err)
+	assert.Equal(t, "jpeg", format)
+	assert.Equal(t, image.Pt(320, 100), thumb.Bounds().Size())
+
+	medium, format, err := image.Decode(bytes.NewReader(rendered["medium.webp"]))
+	require.NoError(t, err)
+	assert.Equal(t, "webp", format)
+	assert.Equal(t, image.Pt(640, 200), medium.Bounds().Size())
+}
+
+func TestOrient(t *testing.T) {
+	img := testImage(3, 2)
+
+	rotated := orient(img, 6)
+	assert.Equal(t, image.Pt(2, 3), rotated.Bounds().Size())
+	// The bottom-left pixel ends up in the top-left corner after a clockwise turn
+	assert.Equal(t, img.At(0, 1), rotated.At(0, 0))
+}
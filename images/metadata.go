@@ -0,0 +1,156 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	errTruncated = errors.New("truncated image")
+	pngSignature = []byte("\x89PNG\r\n\x1a\n")
+)
+
+// pngMetadataChunks are the PNG chunks that carry metadata rather than pixels.
+var pngMetadataChunks = map[string]bool{"eXIf": true, "tEXt": true, "zTXt": true, "iTXt": true, "tIME": true}
+
+// stripJPEG removes the APP1 (EXIF, XMP), APP13 (IPTC) and comment segments of a JPEG
+// without re-encoding it. It also returns the EXIF orientation found, 1 if there was none.
+func stripJPEG(data []byte) ([]byte, int, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, 0, errors.New("not a JPEG image")
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+	orientation := 1
+
+	for i := 2; ; {
+		if i+4 > len(data) || data[i] != 0xFF {
+			return nil, 0, errTruncated
+		}
+		marker := data[i+1]
+		// Start of scan: the entropy-coded image data follows up to the end of the file
+		if marker == 0xDA {
+			out.Write(data[i:])
+			return out.Bytes(), orientation, nil
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, 0, errTruncated
+		}
+
+		switch marker {
+		case 0xE1:
+			if value, ok := exifOrientation(data[i+4 : end]); ok {
+				orientation = value
+			}
+		case 0xED, 0xFE:
+		default:
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+}
+
+// exifOrientation reads the Orientation tag (0x0112) of IFD0 from an APP1 EXIF payload.
+func exifOrientation(payload []byte) (int, bool) {
+	tiff, ok := bytes.CutPrefix(payload, []byte("Exif\x00\x00"))
+	if !ok || len(tiff) < 8 {
+		return 0, false
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0, false
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 0, false
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for n := 0; n < entries; n++ {
+		entry := ifd + 2 + 12*n
+		if entry+12 > len(tiff) {
+			return 0, false
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			value := int(order.Uint16(tiff[entry+8:]))
+			return value, value >= 1 && value <= 8
+		}
+	}
+	return 0, false
+}
+
+// stripPNG removes the metadata chunks of a PNG image.
+func stripPNG(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, errors.New("not a PNG image")
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(pngSignature)
+
+	for i := len(pngSignature); i < len(data); {
+		if i+8 > len(data) {
+			return nil, errTruncated
+		}
+		end := i + 12 + int(binary.BigEndian.Uint32(data[i:]))
+		if end > len(data) || end < i {
+			return nil, errTruncated
+		}
+		if !pngMetadataChunks[string(data[i+4:i+8])] {
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+
+	return out.Bytes(), nil
+}
+
+// stripWebP removes the EXIF and XMP chunks of an extended WebP image and clears their
+// flags in the VP8X header.
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errors.New("not a WebP image")
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:12])
+
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return nil, errTruncated
+		}
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + size + size%2
+		if end > len(data) || end < i {
+			return nil, errTruncated
+		}
+
+		switch string(data[i : i+4]) {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := bytes.Clone(data[i:end])
+			if len(chunk) > 8 {
+				chunk[8] &^= 0x08 | 0x04
+			}
+			out.Write(chunk)
+		default:
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+
+	stripped := out.Bytes()
+	binary.LittleEndian.PutUint32(stripped[4:], uint32(len(stripped)-8))
+	return stripped, nil
+}
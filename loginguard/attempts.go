@@ -0,0 +1,64 @@
+package loginguard
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"noteapp-framework-backend/config"
+)
+
+// Attempts limits the failed attempts at a secret other than a login password, per key, with the
+// same backoff and lockout as logins. Attempts are counted before they are checked, so
+// concurrent attempts cannot all pass while none has failed yet.
+type Attempts struct {
+	store  Store
+	limits config.LoginLimits
+}
+
+// NoteUnlocks limits the passphrase attempts at locked notes, per note, set up by Init.
+var NoteUnlocks *Attempts
+
+// NewAttempts returns a limiter keeping attempts in the store.
+func NewAttempts(store Store, limits config.LoginLimits) *Attempts {
+	return &Attempts{store: store, limits: limits}
+}
+
+// Reserve counts an attempt of the key at now as failed until Succeed is called. It returns the
+// failures of the key counting this attempt, for Fail, and how long the attempt has to wait
+// instead, zero if it may proceed.
+func (a *Attempts) Reserve(ctx context.Context, key string, now time.Time) (int, time.Duration, error) {
+	until, err := a.store.BlockedUntil(ctx, key)
+	if err != nil {
+		return 0, 0, err
+	}
+	if until.After(now) {
+		return 0, until.Sub(now), nil
+	}
+
+	failures, err := a.store.Fail(ctx, key, now, a.limits.Window)
+	if err != nil {
+		return 0, 0, err
+	}
+	// The attempts racing past the block above are turned away here
+	if failures > a.limits.MaxFailures {
+		return failures, a.limits.Lockout, a.store.Lockout(ctx, key, now.Add(a.limits.Lockout))
+	}
+	return failures, 0, nil
+}
+
+// Fail records that the reserved attempt failed, and returns how long the next attempt has to wait.
+func (a *Attempts) Fail(ctx context.Context, key string, failures int, now time.Time) (time.Duration, error) {
+	delay, _, err := block(ctx, a.store, key, a.limits, failures, now)
+	return delay, err
+}
+
+// Succeed forgets the attempts of the key after one succeeded.
+func (a *Attempts) Succeed(ctx context.Context, key string) error {
+	return a.store.Reset(ctx, key)
+}
+
+// NoteKey returns the key counting the attempts at a locked note.
+func NoteKey(noteID int) string {
+	return "note:" + strconv.Itoa(noteID)
+}
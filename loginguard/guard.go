@@ -0,0 +1,209 @@
+package loginguard
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"noteapp-framework-backend/config"
+)
+
+// Store keeps the failed logins of keys. Implementations are safe for concurrent use.
+type Store interface {
+	// Fail records a failed login of the key at now and returns its number of failures.
+	// Failures are forgotten once no new one was recorded for window.
+	Fail(ctx context.Context, key string, now time.Time, window time.Duration) (int, error)
+	// Block rejects logins of the key until the given time.
+	Block(ctx context.Context, key string, until time.Time) error
+	// Lockout rejects logins of the key until the given time and forgets its failures, so they
+	// start over once the lockout ends.
+	Lockout(ctx context.Context, key string, until time.Time) error
+	// BlockedUntil returns until when logins of the key are rejected, zero if they never were.
+	BlockedUntil(ctx context.Context, key string) (time.Time, error)
+	// Reset forgets the failures of the key and lifts its block.
+	Reset(ctx context.Context, key string) error
+	// Forgive takes back the last failure of the key, keeping its block.
+	Forgive(ctx context.Context, key string) error
+}
+
+// Kinds of keys failed logins are counted for.
+const (
+	KindUsername = "username"
+	KindIP       = "ip"
+)
+
+// Lockout is a key locked out by a failed login.
+type Lockout struct {
+	Kind     string
+	Failures int
+	Until    time.Time
+}
+
+// Guard limits failed logins per username and per IP address.
+type Guard struct {
+	store Store
+	users config.LoginLimits
+	ips   config.LoginLimits
+}
+
+// Logins is the guard of the login endpoint, set up by Init.
+var Logins *Guard
+
+// Init configures Logins and NoteUnlocks from the environment: LOGIN_GUARD_STORE=memory (default)
+// counts failures in this process only, LOGIN_GUARD_STORE=postgres in the database, shared by
+// every instance.
+func Init() {
+	store, err := storeFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure login guard: %v", err)
+	}
+	Logins = New(store, config.GetLoginUserLimits(), config.GetLoginIPLimits())
+	NoteUnlocks = NewAttempts(store, config.GetNoteUnlockLimits())
+}
+
+func storeFromEnv() (Store, error) {
+	switch name := os.Getenv("LOGIN_GUARD_STORE"); name {
+	case "", "memory":
+		return NewMemory(), nil
+	case "postgres":
+		return NewPostgres(config.DB), nil
+	default:
+		return nil, fmt.Errorf("unknown login guard store %q", name)
+	}
+}
+
+// New returns a guard keeping failures in the store.
+func New(store Store, users, ips config.LoginLimits) *Guard {
+	return &Guard{store: store, users: users, ips: ips}
+}
+
+// Attempt is a login attempt counted by Reserve. It stays counted as failed unless it succeeds.
+type Attempt struct {
+	username, ip             string
+	userFailures, ipFailures int
+}
+
+// Reserve counts a login of the username from the IP address as failed before the password is
+// checked, so concurrent attempts cannot all pass while none has failed yet. It returns how long
+// the login has to wait instead, zero if it may proceed.
+func (g *Guard) Reserve(ctx context.Context, username, ip string, now time.Time) (Attempt, time.Duration, error) {
+	attempt := Attempt{username: username, ip: ip}
+	var wait time.Duration
+	for _, key := range []string{usernameKey(username), ipKey(ip)} {
+		until, err := g.store.BlockedUntil(ctx, key)
+		if err != nil {
+			return attempt, 0, err
+		}
+		if remaining := until.Sub(now); remaining > wait {
+			wait = remaining
+		}
+	}
+	if wait > 0 {
+		return attempt, wait, nil
+	}
+
+	for _, target := range []struct {
+		key      string
+		limits   config.LoginLimits
+		failures *int
+	}{
+		{usernameKey(username), g.users, &attempt.userFailures},
+		{ipKey(ip), g.ips, &attempt.ipFailures},
+	} {
+		failures, err := g.store.Fail(ctx, target.key, now, target.limits.Window)
+		if err != nil {
+			return attempt, 0, err
+		}
+		*target.failures = failures
+		// The attempts racing past the blocks above are turned away here
+		if failures > target.limits.MaxFailures {
+			if err := g.store.Lockout(ctx, target.key, now.Add(target.limits.Lockout)); err != nil {
+				return attempt, 0, err
+			}
+			wait = max(wait, target.limits.Lockout)
+		}
+	}
+	return attempt, wait, nil
+}
+
+// Fail records that the attempt failed. It returns how long the next attempt has to wait and the
+// keys this failure locked out.
+func (g *Guard) Fail(ctx context.Context, attempt Attempt, now time.Time) (time.Duration, []Lockout, error) {
+	var wait time.Duration
+	var lockouts []Lockout
+	for _, target := range []struct {
+		kind     string
+		key      string
+		limits   config.LoginLimits
+		failures int
+	}{
+		{KindUsername, usernameKey(attempt.username), g.users, attempt.userFailures},
+		{KindIP, ipKey(attempt.ip), g.ips, attempt.ipFailures},
+	} {
+		delay, locked, err := block(ctx, g.store, target.key, target.limits, target.failures, now)
+		if err != nil {
+			return 0, nil, err
+		}
+		if delay == 0 {
+			continue
+		}
+		if delay > wait {
+			wait = delay
+		}
+		if locked {
+			lockouts = append(lockouts, Lockout{Kind: target.kind, Failures: target.failures, Until: now.Add(delay)})
+		}
+	}
+	return wait, lockouts, nil
+}
+
+// Succeed forgets the failures of the username after a successful login, and takes the attempt
+// back from the IP address. Its other failures are kept, so one valid account does not let it
+// guess the passwords of others.
+func (g *Guard) Succeed(ctx context.Context, attempt Attempt) error {
+	if err := g.store.Reset(ctx, usernameKey(attempt.username)); err != nil {
+		return err
+	}
+	return g.store.Forgive(ctx, ipKey(attempt.ip))
+}
+
+// backoff returns how long to block a key after its number of failures, and whether it is locked out.
+// Failures past DelayAfter double the delay each time, until MaxFailures locks the key out.
+func backoff(limits config.LoginLimits, failures int) (time.Duration, bool) {
+	if failures >= limits.MaxFailures {
+		return limits.Lockout, true
+	}
+	if failures <= limits.DelayAfter {
+		return 0, false
+	}
+	delay := limits.Delay
+	for i := limits.DelayAfter + 1; i < failures && delay < limits.Lockout; i++ {
+		delay *= 2
+	}
+	return min(delay, limits.Lockout), false
+}
+
+// block blocks the key for the backoff of its failures and returns it, locking the key out once
+// it reached MaxFailures.
+func block(ctx context.Context, store Store, key string, limits config.LoginLimits, failures int, now time.Time) (time.Duration, bool, error) {
+	delay, locked := backoff(limits, failures)
+	var err error
+	switch {
+	case locked:
+		err = store.Lockout(ctx, key, now.Add(delay))
+	case delay > 0:
+		err = store.Block(ctx, key, now.Add(delay))
+	}
+	return delay, locked, err
+}
+
+func usernameKey(username string) string {
+	return KindUsername + ":" + strings.ToLower(username)
+}
+
+func ipKey(ip string) string {
+	return KindIP + ":" + ip
+}
@@ -0,0 +1,179 @@
+package loginguard
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"noteapp-framework-backend/config"
+)
+
+var testLimits = config.LoginLimits{
+	MaxFailures: 5,
+	DelayAfter:  2,
+	Delay:       time.Second,
+	Window:      time.Hour,
+	Lockout:     10 * time.Minute,
+}
+
+func TestBackoff(t *testing.T) {
+	for failures, want := range map[int]time.Duration{
+		1: 0,
+		2: 0,
+		3: time.Second,
+		4: 2 * time.Second,
+		5: 10 * time.Minute,
+		9: 10 * time.Minute,
+	} {
+		delay, locked := backoff(testLimits, failures)
+		assert.Equal(t, want, delay, "failures %d", failures)
+		assert.Equal(t, failures >= 5, locked, "failures %d", failures)
+	}
+
+	// Delays never exceed the lockout
+	delay, locked := backoff(config.LoginLimits{MaxFailures: 100, Delay: time.Second, Lockout: time.Minute}, 90)
+	assert.Equal(t, time.Minute, delay)
+	assert.False(t, locked)
+}
+
+func TestGuardLocksOutUsername(t *testing.T) {
+	ctx := context.Background()
+	guard := New(NewMemory(), testLimits, config.LoginLimits{MaxFailures: 100, Window: time.Hour, Lockout: time.Hour})
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	for i := 1; i <= 4; i++ {
+		attempt, wait, err := guard.Reserve(ctx, "Alice", "10.0.0.1", now)
+		require.NoError(t, err)
+		require.Zero(t, wait, "attempt %d", i)
+
+		wait, lockouts, err := guard.Fail(ctx, attempt, now)
+		require.NoError(t, err)
+		assert.Empty(t, lockouts)
+		if i > 2 {
+			assert.Positive(t, wait)
+			now = now.Add(wait)
+		}
+	}
+
+	// The fifth failure locks the username out, whatever its case and address
+	attempt, wait, err := guard.Reserve(ctx, "alice", "10.0.0.2", now)
+	require.NoError(t, err)
+	require.Zero(t, wait)
+	_, lockouts, err := guard.Fail(ctx, attempt, now)
+	require.NoError(t, err)
+	require.Len(t, lockouts, 1)
+	assert.Equal(t, KindUsername, lockouts[0].Kind)
+	assert.Equal(t, now.Add(10*time.Minute), lockouts[0].Until)
+
+	_, wait, err = guard.Reserve(ctx, "ALICE", "10.0.0.3", now)
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Minute, wait)
+	attempt, wait, err = guard.Reserve(ctx, "bob", "10.0.0.1", now)
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+	require.NoError(t, guard.Succeed(ctx, attempt))
+
+	_, wait, err = guard.Reserve(ctx, "alice", "10.0.0.1", now.Add(10*time.Minute))
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+}
+
+func TestGuardCountsConcurrentAttempts(t *testing.T) {
+	ctx := context.Background()
+	guard := New(NewMemory(), testLimits, config.LoginLimits{MaxFailures: 100, Window: time.Hour, Lockout: time.Hour})
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	// A burst of attempts reserved before any of them failed only lets MaxFailures through
+	allowed := 0
+	for i := 0; i < 20; i++ {
+		_, wait, err := guard.Reserve(ctx, "alice", "10.0.0.1", now)
+		require.NoError(t, err)
+		if wait == 0 {
+			allowed++
+		}
+	}
+	assert.Equal(t, 5, allowed)
+}
+
+func TestGuardLocksOutIP(t *testing.T) {
+	ctx := context.Background()
+	guard := New(NewMemory(), config.LoginLimits{MaxFailures: 100, Window: time.Hour, Lockout: time.Hour},
+		config.LoginLimits{MaxFailures: 3, DelayAfter: 3, Window: time.Hour, Lockout: time.Hour})
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	// Successful logins do not count against the address
+	for i := 0; i < 5; i++ {
+		attempt, wait, err := guard.Reserve(ctx, "erin", "10.0.0.1", now)
+		require.NoError(t, err)
+		require.Zero(t, wait)
+		require.NoError(t, guard.Succeed(ctx, attempt))
+	}
+
+	// Guessing a different username each time does not get around the limit of the address
+	var lockouts []Lockout
+	for _, username := range []string{"alice", "bob", "carol"} {
+		attempt, wait, err := guard.Reserve(ctx, username, "10.0.0.1", now)
+		require.NoError(t, err)
+		require.Zero(t, wait)
+		_, lockouts, err = guard.Fail(ctx, attempt, now)
+		require.NoError(t, err)
+	}
+	require.Len(t, lockouts, 1)
+	assert.Equal(t, KindIP, lockouts[0].Kind)
+
+	_, wait, err := guard.Reserve(ctx, "dave", "10.0.0.1", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, wait)
+}
+
+func TestMemoryForgetsOldFailures(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemory()
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	for i := 1; i <= 3; i++ {
+		failures, err := store.Fail(ctx, "user:alice", now, time.Minute)
+		require.NoError(t, err)
+		assert.Equal(t, i, failures)
+	}
+
+	failures, err := store.Fail(ctx, "user:alice", now.Add(2*time.Minute), time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 1, failures)
+
+	// Stale entries are dropped
+	_, err = store.Fail(ctx, "user:bob", now.Add(10*time.Minute), time.Minute)
+	require.NoError(t, err)
+	assert.NotContains(t, store.entries, "user:alice")
+}
+
+func TestAttemptsCountBeforeChecking(t *testing.T) {
+	ctx := context.Background()
+	attempts := NewAttempts(NewMemory(), config.LoginLimits{MaxFailures: 3, DelayAfter: 3, Window: time.Hour, Lockout: time.Hour})
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	// Attempts that have not failed yet count all the same
+	var failures int
+	for i := 1; i <= 3; i++ {
+		var wait time.Duration
+		var err error
+		failures, wait, err = attempts.Reserve(ctx, NoteKey(7), now)
+		require.NoError(t, err)
+		assert.Zero(t, wait, "attempt %d", i)
+	}
+	_, wait, err := attempts.Reserve(ctx, NoteKey(7), now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, wait)
+
+	wait, err = attempts.Fail(ctx, NoteKey(7), failures, now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, wait)
+
+	// Failures start over after the lockout
+	_, wait, err = attempts.Reserve(ctx, NoteKey(7), now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+}
@@ -0,0 +1,112 @@
+package loginguard
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory keeps failed logins in the memory of this process. Every instance counts on its own.
+type Memory struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+	pruned  time.Time
+}
+
+type memoryEntry struct {
+	failures     int
+	expiresAt    time.Time
+	blockedUntil time.Time
+}
+
+// NewMemory returns an empty in-memory store.
+func NewMemory() *Memory {
+	return &Memory{entries: make(map[string]*memoryEntry)}
+}
+
+func (m *Memory) Fail(ctx context.Context, key string, now time.Time, window time.Duration) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.prune(now, window)
+	entry, ok := m.entries[key]
+	if !ok {
+		entry = &memoryEntry{}
+		m.entries[key] = entry
+	}
+	if entry.expiresAt.Before(now) {
+		entry.failures = 0
+	}
+	entry.failures++
+	entry.expiresAt = now.Add(window)
+	return entry.failures, nil
+}
+
+func (m *Memory) Block(ctx context.Context, key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[key]
+	if !ok {
+		entry = &memoryEntry{}
+		m.entries[key] = entry
+	}
+	entry.blockedUntil = until
+	return nil
+}
+
+func (m *Memory) Lockout(ctx context.Context, key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[key]
+	if !ok {
+		entry = &memoryEntry{}
+		m.entries[key] = entry
+	}
+	entry.failures = 0
+	entry.blockedUntil = until
+	return nil
+}
+
+func (m *Memory) BlockedUntil(ctx context.Context, key string) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if entry, ok := m.entries[key]; ok {
+		return entry.blockedUntil, nil
+	}
+	return time.Time{}, nil
+}
+
+func (m *Memory) Reset(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.entries, key)
+	return nil
+}
+
+func (m *Memory) Forgive(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if entry, ok := m.entries[key]; ok && entry.failures > 0 {
+		entry.failures--
+	}
+	return nil
+}
+
+// prune drops the entries whose failures are forgotten and whose block is over, at most once per
+// window, so guessing many usernames does not grow the map forever.
+func (m *Memory) prune(now time.Time, window time.Duration) {
+	if now.Sub(m.pruned) < window {
+		return
+	}
+	m.pruned = now
+	for key, entry := range m.entries {
+		if entry.expiresAt.Before(now) && !entry.blockedUntil.After(now) {
+			delete(m.entries, key)
+		}
+	}
+}
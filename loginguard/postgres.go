@@ -0,0 +1,101 @@
+package loginguard
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Postgres keeps failed logins in the login_attempts table, shared by every instance using the database.
+type Postgres struct {
+	db *gorm.DB
+
+	mu     sync.Mutex
+	pruned time.Time
+}
+
+type loginAttempt struct {
+	Key          string `gorm:"primaryKey"`
+	Failures     int
+	ExpiresAt    time.Time
+	BlockedUntil *time.Time
+}
+
+func (loginAttempt) TableName() string {
+	return "login_attempts"
+}
+
+// NewPostgres returns a store using the database.
+func NewPostgres(db *gorm.DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+func (p *Postgres) Fail(ctx context.Context, key string, now time.Time, window time.Duration) (int, error) {
+	now = now.UTC()
+	if err := p.prune(ctx, now, window); err != nil {
+		return 0, err
+	}
+
+	// The upsert counts concurrent failures from several instances exactly
+	var failures int
+	err := p.db.WithContext(ctx).Raw(`
+		INSERT INTO login_attempts (key, failures, expires_at) VALUES (?, 1, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_attempts.expires_at < ? THEN 1 ELSE login_attempts.failures + 1 END,
+			expires_at = EXCLUDED.expires_at
+		RETURNING failures`, key, now.Add(window), now).Scan(&failures).Error
+	return failures, err
+}
+
+func (p *Postgres) Block(ctx context.Context, key string, until time.Time) error {
+	return p.db.WithContext(ctx).Model(&loginAttempt{}).Where("key = ?", key).Update("blocked_until", until.UTC()).Error
+}
+
+func (p *Postgres) Lockout(ctx context.Context, key string, until time.Time) error {
+	until = until.UTC()
+	return p.db.WithContext(ctx).Exec(`
+		INSERT INTO login_attempts (key, failures, expires_at, blocked_until) VALUES (?, 0, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET failures = 0, blocked_until = EXCLUDED.blocked_until`, key, until, until).Error
+}
+
+func (p *Postgres) BlockedUntil(ctx context.Context, key string) (time.Time, error) {
+	var attempt loginAttempt
+	if err := p.db.WithContext(ctx).First(&attempt, "key = ?", key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	if attempt.BlockedUntil == nil {
+		return time.Time{}, nil
+	}
+	return *attempt.BlockedUntil, nil
+}
+
+func (p *Postgres) Reset(ctx context.Context, key string) error {
+	return p.db.WithContext(ctx).Where("key = ?", key).Delete(&loginAttempt{}).Error
+}
+
+func (p *Postgres) Forgive(ctx context.Context, key string) error {
+	return p.db.WithContext(ctx).Model(&loginAttempt{}).Where("key = ? AND failures > 0", key).
+		Update("failures", gorm.Expr("failures - 1")).Error
+}
+
+// prune deletes the rows whose failures are forgotten and whose block is over, at most once per window
+// and instance.
+func (p *Postgres) prune(ctx context.Context, now time.Time, window time.Duration) error {
+	p.mu.Lock()
+	if now.Sub(p.pruned) < window {
+		p.mu.Unlock()
+		return nil
+	}
+	p.pruned = now
+	p.mu.Unlock()
+
+	return p.db.WithContext(ctx).
+		Where("expires_at < ? AND (blocked_until IS NULL OR blocked_until <= ?)", now, now).
+		Delete(&loginAttempt{}).Error
+}
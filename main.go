@@ -1,11 +1,18 @@
 package main
 
 import (
+	"context"
 	"log"
+	_ "time/tzdata" // Time zones of the users must be available in the container as well
 
 	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/encryption"
 	"noteapp-framework-backend/handlers"
+	"noteapp-framework-backend/loginguard"
 	"noteapp-framework-backend/middleware"
+	"noteapp-framework-backend/models"
+	"noteapp-framework-backend/reminders"
+	"noteapp-framework-backend/storage"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -21,52 +28,188 @@ func main() {
 
 	// Initialize DB
 	config.DBInit()
+	storage.Init()
+	encryption.Init()
+	loginguard.Init()
+
+	// Fire due reminders in the background
+	reminders.Start(context.Background())
 
 	r := gin.Default()
+	if err := r.SetTrustedProxies(config.GetTrustedProxies()); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
 
 	// Enable CORS
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:5173"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-Workspace-ID"},
+		ExposeHeaders:    []string{"Content-Length", "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"},
 		AllowCredentials: true,
 	}))
 
+	// Authenticated routes share one budget per user, exports have a stricter one on top of it.
+	// Every address has a budget before authentication, so invalid tokens are limited as well.
+	ipLimit := middleware.RateLimit(config.GetIPRateLimit())
+	apiLimit := middleware.RateLimit(config.GetAPIRateLimit())
+	exportLimit := middleware.RateLimit(config.GetExportRateLimit())
+
 	// Public routes
-	r.POST("/register", handlers.Register)
-	r.POST("/login", handlers.Login)
-	r.POST("/refresh-token", handlers.RefreshToken)
-	r.POST("/logout", handlers.Logout)
+	public := r.Group("/", middleware.RateLimit(config.GetPublicRateLimit()))
+	public.POST("/register", handlers.Register)
+	public.POST("/login", handlers.Login)
+	public.POST("/refresh-token", handlers.RefreshToken)
+	public.POST("/logout", handlers.Logout)
+
+	// Routes authenticated by their URL have a budget of their own, so loading images does not
+	// use up the budget of logins
+	signed := r.Group("/", middleware.RateLimit(config.GetSignedURLRateLimit()))
+	// Calendar apps subscribe with the secret token of the URL instead of a JWT; :token ends in .ics
+	signed.GET("/calendar/:token", handlers.GetCalendarFeed)
+	// Images are loaded by <img> tags, which cannot send a JWT; the URL carries a signature instead
+	signed.GET("/images/:id/:variant", handlers.GetImage)
 
 	// Protected routes
 	protected := r.Group("/")
-	protected.Use(middleware.AuthMiddleware())
+	protected.Use(ipLimit, middleware.AuthMiddleware(), apiLimit)
+	{
+		// Workspace Routes
+		protected.POST("/workspaces", handlers.CreateWorkspace)
+		protected.GET("/workspaces", handlers.GetWorkspaces)
+		protected.PUT("/workspaces/:id", handlers.UpdateWorkspace)
+		protected.GET("/workspaces/:id/members", handlers.GetWorkspaceMembers)
+		protected.PUT("/workspaces/:id/members/:userId", handlers.UpdateWorkspaceMember)
+		protected.DELETE("/workspaces/:id/members/:userId", handlers.RemoveWorkspaceMember)
+		protected.POST("/workspaces/:id/invitations", handlers.InviteToWorkspace)
+		protected.GET("/workspaces/:id/invitations", handlers.GetWorkspaceInvitations)
+		protected.GET("/me/invitations", handlers.GetMyInvitations)
+		protected.POST("/invitations/:id/accept", handlers.AcceptInvitation)
+		protected.DELETE("/invitations/:id", handlers.DeleteInvitation)
+	}
+
+	// Admin routes
+	admin := r.Group("/admin")
+	admin.Use(ipLimit, middleware.AuthMiddleware(), apiLimit, middleware.RequireRole(models.UserRoleAdmin))
+	{
+		admin.GET("/users", handlers.GetUsers)
+		admin.GET("/users/:id/usage", handlers.GetUserUsage)
+		admin.PUT("/users/:id/disable", handlers.DisableUser)
+		admin.PUT("/users/:id/enable", handlers.EnableUser)
+		admin.POST("/users/:id/logout", handlers.LogoutUser)
+		admin.PUT("/users/:id/password", handlers.ResetUserPassword)
+		admin.PUT("/users/:id/role", handlers.UpdateUserRole)
+		admin.GET("/stats", handlers.GetStats)
+	}
+
+	// The other protected routes run in the active workspace: the personal workspace, the one of
+	// the X-Workspace-ID header, or the one of the /w/:workspace prefix
+	registerWorkspaceRoutes(protected.Group("/", middleware.WorkspaceMiddleware()), exportLimit)
+	registerWorkspaceRoutes(protected.Group("/w/:workspace", middleware.WorkspaceMiddleware()), exportLimit)
+
+	r.Run(":8080")
+}
+
+// registerWorkspaceRoutes registers the routes that run in the active workspace. Exports generating
+// files go through exportLimit.
+func registerWorkspaceRoutes(protected *gin.RouterGroup, exportLimit gin.HandlerFunc) {
 	{
 		// Notebook Routes
 		protected.POST("/notebooks", handlers.CreateNotebook)
 		protected.GET("/notebooks", handlers.GetNotebooks)
+		protected.GET("/notebooks/tree", handlers.GetNotebookTree)
 		protected.GET("/notebooks/:id", handlers.GetNotebook)
 		protected.PUT("/notebooks/:id", handlers.UpdateNotebook)
 		protected.DELETE("/notebooks/:id", handlers.DeleteNotebook)
+		protected.PUT("/notebooks/:id/move", handlers.MoveNotebook)
+		protected.PUT("/notebooks/:id/archive", handlers.ArchiveNotebook)
 		protected.GET("/notebookscount/", handlers.GetNotebookCount)
 		protected.GET("/notescount/:notebookid", handlers.GetNoteCount)
 		protected.GET("/notebookname/:id", handlers.GetNotebookName)
-		protected.POST("/notebooks/:id/export", handlers.ExportNotebook)
+		protected.POST("/notebooks/:id/export", exportLimit, handlers.ExportNotebook)
+		protected.GET("/notebooks/:id/activity", handlers.GetNotebookActivity)
 
 		// Note Routes
 		protected.POST("/notes", handlers.CreateNote)
+		protected.GET("/notes/recent", handlers.GetRecentNotes)
 		protected.GET("/notes/:notebookid", handlers.GetNotes)
 		protected.GET("/notes/:notebookid/pagination", handlers.GetNotesWithPagination)
+		// GET routes below /notes/ must reuse the :notebookid wildcard; these take a note ID
+		protected.GET("/notes/:notebookid/links", handlers.GetNoteLinks)
+		protected.GET("/notes/:notebookid/backlinks", handlers.GetNoteBacklinks)
+		protected.GET("/notes/:notebookid/reminders", handlers.GetNoteReminders)
+		protected.GET("/notes/:notebookid/attachments", handlers.GetNoteAttachments)
+		protected.GET("/links/dangling", handlers.GetDanglingLinks)
+		protected.GET("/graph", handlers.GetGraph)
 		protected.GET("/notebyid/:notebookid/:noteid", handlers.GetNote)
 		protected.PUT("/notes/:id", handlers.UpdateNote)
 		protected.DELETE("/notes/:id", handlers.DeleteNote)
-		protected.POST("/notes/:id/export", handlers.ExportNote)
+		protected.POST("/notes/:id/export", exportLimit, handlers.ExportNote)
+		protected.POST("/notes/:id/move", handlers.MoveNote)
+		protected.POST("/notes/:id/copy", handlers.CopyNote)
+		protected.POST("/notes/move", handlers.MoveNotes)
+		protected.POST("/notes/copy", handlers.CopyNotes)
+		protected.POST("/notes/bulk", handlers.BulkNotes)
+		protected.PUT("/notes/:id/flags", handlers.UpdateNoteFlags)
+		protected.GET("/favorites", handlers.GetFavorites)
+		protected.POST("/notes/from-template/:templateId", handlers.CreateNoteFromTemplate)
+		protected.POST("/notes/:id/lock", handlers.LockNote)
+		protected.DELETE("/notes/:id/lock", handlers.RemoveNoteLock)
+		protected.POST("/notes/:id/unlock", handlers.UnlockNote)
+
+		// Template Routes
+		protected.POST("/templates", handlers.CreateTemplate)
+		protected.GET("/templates", handlers.GetTemplates)
+		protected.GET("/templates/:id", handlers.GetTemplate)
+		protected.PUT("/templates/:id", handlers.UpdateTemplate)
+		protected.DELETE("/templates/:id", handlers.DeleteTemplate)
+
+		// Task Routes
+		protected.GET("/tasks", handlers.GetTasks)
+		protected.PUT("/tasks/:id", handlers.UpdateTask)
+
+		// Attachment Routes
+		protected.POST("/notes/:id/attachments", handlers.UploadAttachment)
+		protected.GET("/attachments/:id", handlers.DownloadAttachment)
+		protected.GET("/attachments/:id/url", handlers.GetImageURL)
+		protected.DELETE("/attachments/:id", handlers.DeleteAttachment)
+
+		// Reminder Routes
+		protected.POST("/notes/:id/reminders", handlers.CreateReminder)
+		protected.GET("/reminders", handlers.GetReminders)
+		protected.PUT("/reminders/:id", handlers.UpdateReminder)
+		protected.DELETE("/reminders/:id", handlers.DeleteReminder)
+
+		// End-to-End Encryption Routes
+		protected.PUT("/me/public-key", handlers.RegisterPublicKey)
+		protected.GET("/public-keys/:username", handlers.GetPublicKey)
+		protected.GET("/me/notebook-keys", handlers.GetMyNotebookKeys)
+		protected.POST("/notebooks/encrypted", handlers.CreateEncryptedNotebook)
+		protected.GET("/notebooks/:id/members", handlers.GetNotebookMembers)
+		protected.POST("/notebooks/:id/members", handlers.AddNotebookMember)
+		protected.DELETE("/notebooks/:id/members/:userId", handlers.RemoveNotebookMember)
+		protected.POST("/notebooks/:id/rotate-key", handlers.RotateNotebookKey)
+		protected.GET("/notebooks/:id/encrypted-notes", handlers.GetEncryptedNotes)
 
 		// User Info Route
 		protected.GET("/me", handlers.GetUserInfo)
+		protected.GET("/me/activity", handlers.GetMyActivity)
+		protected.GET("/me/usage", handlers.GetUsage)
+		protected.PUT("/me/settings", handlers.UpdateUserSettings)
+		protected.POST("/me/calendar/token", handlers.RegenerateCalendarToken)
+		protected.DELETE("/me/calendar/token", handlers.DeleteCalendarToken)
 		protected.POST("/changeusername", handlers.ChangeUsername)
-	}
 
-	r.Run(":8080")
+		// Journal Routes
+		protected.GET("/daily/calendar", handlers.GetJournalCalendar)
+		protected.GET("/daily/:date", handlers.GetDailyNote)
+
+		// Notification Routes
+		protected.GET("/notifications", handlers.GetNotifications)
+		protected.GET("/notifications/stream", handlers.StreamNotifications)
+		protected.PUT("/notifications/read", handlers.MarkAllNotificationsRead)
+		protected.PUT("/notifications/:id/read", handlers.MarkNotificationRead)
+		protected.GET("/notifications/preferences", handlers.GetNotificationPreferences)
+		protected.PUT("/notifications/preferences", handlers.UpdateNotificationPreferences)
+	}
 }
@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/models"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -57,8 +59,53 @@ func AuthMiddleware() gin.HandlerFunc {
 			}
 		}
 
-		// Add user ID to context
+		// Disabled accounts and revoked sessions are refused even with a valid token
+		var user models.User
+		if err := config.DB.Select("id", "role", "disabled", "sessions_revoked_at").First(&user, "id = ?", userID).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			c.Abort()
+			return
+		}
+		if user.Disabled {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is disabled"})
+			c.Abort()
+			return
+		}
+		if user.TokenRevoked(TokenIssuedAt(claims)) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+			c.Abort()
+			return
+		}
+
+		// Add user ID and role to context
 		c.Set("user_id", userID)
+		c.Set("user_role", user.Role)
+		// Tokens issued before sessions were introduced have no session ID
+		if sessionID, ok := claims["sid"].(string); ok {
+			c.Set("session_id", sessionID)
+		}
 		c.Next()
 	}
 }
+
+// RequireRole only lets users with the given role through. It runs after AuthMiddleware.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("user_role") != role {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// TokenIssuedAt returns the iat claim of a token. Tokens issued before the claim was added
+// count as issued at the zero time, so revoking sessions revokes them as well.
+func TokenIssuedAt(claims jwt.MapClaims) time.Time {
+	issuedAt, err := claims.GetIssuedAt()
+	if err != nil || issuedAt == nil {
+		return time.Time{}
+	}
+	return issuedAt.Time
+}
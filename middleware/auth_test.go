@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+
+	"noteapp-framework-backend/models"
+)
+
+func TestRequireRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	request := func(role string) int {
+		router := gin.New()
+		router.GET("/admin", func(c *gin.Context) {
+			c.Set("user_role", role)
+		}, RequireRole(models.UserRoleAdmin), func(c *gin.Context) {
+			c.Status(http.StatusNoContent)
+		})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/admin", nil))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusNoContent, request(models.UserRoleAdmin))
+	assert.Equal(t, http.StatusForbidden, request(models.UserRoleUser))
+	assert.Equal(t, http.StatusForbidden, request(""))
+}
+
+func TestTokenRevoked(t *testing.T) {
+	revokedAt := time.Date(2026, 10, 19, 12, 0, 0, 500_000_000, time.UTC)
+	user := models.User{SessionsRevokedAt: &revokedAt}
+
+	assert.True(t, user.TokenRevoked(TokenIssuedAt(jwt.MapClaims{"iat": float64(revokedAt.Add(-time.Hour).Unix())})))
+	// Same second: the token may have been issued before the revocation
+	assert.True(t, user.TokenRevoked(TokenIssuedAt(jwt.MapClaims{"iat": float64(revokedAt.Unix())})))
+	assert.False(t, user.TokenRevoked(TokenIssuedAt(jwt.MapClaims{"iat": float64(revokedAt.Add(time.Second).Unix())})))
+	// Tokens without iat predate the claim
+	assert.True(t, user.TokenRevoked(TokenIssuedAt(jwt.MapClaims{})))
+
+	assert.False(t, models.User{}.TokenRevoked(time.Time{}))
+}
@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"noteapp-framework-backend/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit limits requests with a token bucket per user, or per IP address before
+// authentication. Each call returns a limiter with its own buckets, so routes sharing a budget
+// must share the handler. Responses carry X-RateLimit-Limit, X-RateLimit-Remaining and
+// X-RateLimit-Reset, the seconds until the bucket is full again. Buckets live in the memory
+// of this process.
+func RateLimit(limit config.RateLimit) gin.HandlerFunc {
+	limiter := newRateLimiter(limit)
+	return func(c *gin.Context) {
+		key := "ip:" + c.ClientIP()
+		if userID := c.GetString("user_id"); userID != "" {
+			key = "user:" + userID
+		}
+
+		allowed, remaining, reset, retry := limiter.take(key, time.Now())
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit.Requests))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(seconds(reset)))
+		if !allowed {
+			c.Header("Retry-After", strconv.Itoa(seconds(retry)))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+type rateLimiter struct {
+	capacity float64
+	rate     float64 // tokens per second
+	period   time.Duration
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	pruned  time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func newRateLimiter(limit config.RateLimit) *rateLimiter {
+	return &rateLimiter{
+		capacity: float64(limit.Requests),
+		rate:     float64(limit.Requests) / limit.Period.Seconds(),
+		period:   limit.Period,
+		buckets:  make(map[string]*bucket),
+	}
+}
+
+// take takes a token from the bucket of the key. It returns whether there was one, the tokens
+// left, the time until the bucket is full and, when there was none, the time until the next token.
+func (l *rateLimiter) take(key string, now time.Time) (bool, int, time.Duration, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.prune(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.capacity, updated: now}
+		l.buckets[key] = b
+	}
+	b.tokens = l.refill(b, now)
+	b.updated = now
+
+	allowed := b.tokens >= 1
+	var retry time.Duration
+	if allowed {
+		b.tokens--
+	} else {
+		retry = l.duration(1 - b.tokens)
+	}
+	return allowed, int(b.tokens), l.duration(l.capacity - b.tokens), retry
+}
+
+func (l *rateLimiter) refill(b *bucket, now time.Time) float64 {
+	return math.Min(l.capacity, b.tokens+now.Sub(b.updated).Seconds()*l.rate)
+}
+
+// duration returns how long refilling the tokens takes.
+func (l *rateLimiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// prune drops the buckets that have filled up again, which behave like missing ones, at most once per period.
+func (l *rateLimiter) prune(now time.Time) {
+	if now.Sub(l.pruned) < l.period {
+		return
+	}
+	l.pruned = now
+	for key, b := range l.buckets {
+		if l.refill(b, now) >= l.capacity {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// seconds rounds a duration up to whole seconds.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"noteapp-framework-backend/config"
+)
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/export", func(c *gin.Context) {
+		if userID := c.GetHeader("X-Test-User"); userID != "" {
+			c.Set("user_id", userID)
+		}
+	}, RateLimit(config.RateLimit{Requests: 2, Period: time.Hour}), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	request := func(userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/export", nil)
+		req.Header.Set("X-Test-User", userID)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("1")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "1800", w.Header().Get("X-RateLimit-Reset"))
+
+	assert.Equal(t, http.StatusNoContent, request("1").Code)
+	w = request("1")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	// Every user has a budget of their own, and requests without one are limited per address
+	assert.Equal(t, http.StatusNoContent, request("2").Code)
+	assert.Equal(t, http.StatusNoContent, request("").Code)
+	assert.Equal(t, http.StatusNoContent, request("").Code)
+	assert.Equal(t, http.StatusTooManyRequests, request("").Code)
+}
+
+func TestRateLimiterRefills(t *testing.T) {
+	limiter := newRateLimiter(config.RateLimit{Requests: 3, Period: 3 * time.Second})
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		allowed, _, _, _ := limiter.take("user:1", now)
+		assert.True(t, allowed)
+	}
+	allowed, remaining, reset, retry := limiter.take("user:1", now)
+	assert.False(t, allowed)
+	assert.Equal(t, 0, remaining)
+	assert.Equal(t, 3*time.Second, reset)
+	assert.Equal(t, time.Second, retry)
+
+	// One token comes back per second
+	allowed, remaining, _, _ = limiter.take("user:1", now.Add(time.Second))
+	assert.True(t, allowed)
+	assert.Equal(t, 0, remaining)
+
+	// Full buckets are dropped
+	limiter.take("user:2", now.Add(time.Hour))
+	assert.NotContains(t, limiter.buckets, "user:1")
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WorkspaceMiddleware resolves the active workspace of the request, taken from the
+// /w/:workspace path prefix, else from the X-Workspace-ID header, else the personal workspace
+// of the user. It runs after AuthMiddleware and rejects workspaces the user is not a member of.
+func WorkspaceMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString("user_id")
+
+		requested := c.Param("workspace")
+		if requested == "" {
+			requested = c.GetHeader("X-Workspace-ID")
+		}
+
+		query := config.DB.Where("user_id = ?", userID)
+		if requested != "" {
+			workspaceID, err := strconv.ParseUint(requested, 10, 32)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+				c.Abort()
+				return
+			}
+			query = query.Where("workspace_id = ?", workspaceID)
+		} else {
+			query = query.Where("workspace_id = (?)", config.DB.Model(&models.Workspace{}).Select("id").Where("personal_user_id = ?", userID))
+		}
+
+		var member models.WorkspaceMember
+		if err := query.First(&member).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Workspace not found or access denied"})
+			c.Abort()
+			return
+		}
+
+		c.Set("workspace_id", member.WorkspaceID)
+		c.Set("workspace_role", member.Role)
+		c.Next()
+	}
+}
@@ -1,9 +1,40 @@
 package models
 
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// LockedContentPrefix starts the Content of a locked note, encrypted with a key derived from
+// its passphrase: "$argon2id$v=19$m=...,t=...,p=...$<salt>$<nonce and ciphertext>".
+const LockedContentPrefix = "$argon2id$"
+
 type Note struct {
-	ID         int    `json:"id"`
-	Title      string `json:"title"`
-	Content    string `json:"content"`
-	NotebookID uint   `json:"notebook_id"`
-	UserID     uint   `json:"user_id"`
+	ID         int       `json:"id"`
+	Title      string    `json:"title" gorm:"serializer:encrypted"` // Title and Content are encrypted at rest when configured, see package encryption
+	Content    string    `json:"content" gorm:"serializer:encrypted"`
+	NotebookID uint      `json:"notebook_id"`
+	UserID     uint      `json:"user_id"`
+	Pinned     bool      `json:"pinned"`
+	Favorite   bool      `json:"favorite"`
+	Archived   bool      `json:"archived"`
+	Locked     bool      `json:"locked"`                // Content is protected by a passphrase of its own
+	KeyVersion int       `json:"key_version,omitempty"` // Version of the notebook key encrypting Title and Content, 0 for plaintext
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// IsLockedContent reports whether the content is still encrypted with a note passphrase.
+func (n Note) IsLockedContent() bool {
+	return n.Locked && strings.HasPrefix(n.Content, LockedContentPrefix)
+}
+
+// MarshalJSON leaves the content of locked notes empty until a handler decrypted it.
+func (n Note) MarshalJSON() ([]byte, error) {
+	type note Note
+	if n.IsLockedContent() {
+		n.Content = ""
+	}
+	return json.Marshal(note(n))
 }
@@ -1,7 +1,17 @@
 package models
 
+import "time"
+
 type Notebook struct {
-	ID     int    `json:"id"`
-	Name   string `json:"name"`
-	UserID uint   `json:"user_id"`
+	ID               int       `json:"id"`
+	Name             string    `json:"name"`
+	UserID           uint      `json:"user_id"` // Creator of the notebook
+	WorkspaceID      uint      `json:"workspace_id"`
+	ParentID         *uint     `json:"parent_id"`
+	Archived         bool      `json:"archived"`
+	Encrypted        bool      `json:"encrypted"`                   // Notes are end-to-end encrypted by the clients
+	KeyVersion       int       `json:"key_version,omitempty"`       // Current version of the notebook key of an encrypted notebook
+	RotationRequired bool      `json:"rotation_required,omitempty"` // A member holding the key left the workspace, notes are read-only until the key is rotated
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
 }
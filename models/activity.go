@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// Activity actions.
+const (
+	ActivityNotebookCreate = "notebook.create"
+	ActivityNotebookUpdate = "notebook.update"
+	ActivityNotebookDelete = "notebook.delete"
+	ActivityNotebookMove   = "notebook.move"
+	ActivityNotebookExport = "notebook.export"
+	ActivityNoteCreate     = "note.create"
+	ActivityNoteUpdate     = "note.update"
+	ActivityNoteDelete     = "note.delete"
+	ActivityNoteMove       = "note.move"
+	ActivityNoteCopy       = "note.copy"
+	ActivityNoteExport     = "note.export"
+	ActivityUserRegister   = "user.register"
+	ActivityUserLogin      = "user.login"
+	ActivityUserLoginFail  = "user.login_failed"
+	ActivityUserLockout    = "user.lockout"
+	ActivityUserLogout     = "user.logout"
+	ActivityUserRename     = "user.change_username"
+	ActivityUserDisable    = "user.disable"
+	ActivityUserEnable     = "user.enable"
+	ActivityUserLogoutAll  = "user.logout_all"
+	ActivityUserResetPass  = "user.reset_password"
+	ActivityUserRoleChange = "user.change_role"
+)
+
+// Activity is an append-only audit log entry. Before and After hold JSON summaries of the target.
+type Activity struct {
+	ID         int       `json:"id"`
+	ActorID    *uint     `json:"actor_id"`
+	Action     string    `json:"action"`
+	TargetType string    `json:"target_type"`
+	TargetID   *uint     `json:"target_id"`
+	NotebookID *uint     `json:"notebook_id"`
+	Before     string    `json:"before,omitempty"`
+	After      string    `json:"after,omitempty"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+}
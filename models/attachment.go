@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Attachment is a file uploaded to a note. The content is stored once per SHA256 in the
+// attachment storage, however many notes it is attached to.
+type Attachment struct {
+	ID          int       `json:"id"`
+	UserID      uint      `json:"user_id"`
+	NoteID      uint      `json:"note_id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256" gorm:"column:sha256"`
+	Width       int       `json:"width,omitempty"`  // Images only
+	Height      int       `json:"height,omitempty"` // Images only
+	CreatedAt   time.Time `json:"created_at"`
+}
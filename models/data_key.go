@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// DataKey is a per-user key encrypting note titles and contents at rest. It is only stored
+// wrapped by the master key MasterKeyID; the current data key of a user encrypts new values.
+type DataKey struct {
+	ID          uint      `json:"id"`
+	UserID      uint      `json:"user_id"`
+	MasterKeyID string    `json:"master_key_id"`
+	WrappedKey  []byte    `json:"-"`
+	Current     bool      `json:"current"`
+	CreatedAt   time.Time `json:"created_at"`
+}
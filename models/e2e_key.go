@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// UserPublicKey is the public key a user registered for end-to-end encrypted notebooks.
+// The matching private key never leaves the user's devices.
+type UserPublicKey struct {
+	UserID    uint      `json:"user_id" gorm:"primaryKey"`
+	Algorithm string    `json:"algorithm"`
+	PublicKey string    `json:"public_key"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NotebookKey is one version of the key of an encrypted notebook, wrapped by the client with
+// the public key of a member. The server only ever sees wrapped keys.
+type NotebookKey struct {
+	NotebookID uint      `json:"notebook_id" gorm:"primaryKey"`
+	UserID     uint      `json:"user_id" gorm:"primaryKey"`
+	KeyVersion int       `json:"key_version" gorm:"primaryKey"`
+	WrappedKey string    `json:"wrapped_key"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// JournalEntry maps a day of the user's journal to its daily note.
+type JournalEntry struct {
+	UserID    uint      `json:"-" gorm:"primaryKey"`
+	EntryDate time.Time `json:"date" gorm:"primaryKey;type:date"`
+	NoteID    uint      `json:"note_id"`
+}
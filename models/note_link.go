@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// NoteLink is a [[wiki link]] from one note to another. TargetNoteID is nil while the link is dangling.
+// UserID is the owner of the source note, whose data key encrypts TargetTitle at rest.
+type NoteLink struct {
+	ID           int       `json:"id"`
+	UserID       uint      `json:"-"`
+	SourceNoteID uint      `json:"source_note_id"`
+	TargetNoteID *uint     `json:"target_note_id"`
+	TargetTitle  string    `json:"target_title" gorm:"serializer:encrypted"`
+	CreatedAt    time.Time `json:"created_at"`
+}
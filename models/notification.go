@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Notification event types.
+const (
+	NotificationMention  = "mention"
+	NotificationReminder = "reminder"
+)
+
+// NotificationEventTypes lists every event type a user can toggle in their preferences.
+var NotificationEventTypes = []string{NotificationMention, NotificationReminder}
+
+type Notification struct {
+	ID        int       `json:"id"`
+	UserID    uint      `json:"user_id"`
+	ActorID   *uint     `json:"actor_id"`
+	Type      string    `json:"type"`
+	NoteID    *uint     `json:"note_id"`
+	Message   string    `json:"message" gorm:"serializer:encrypted"`
+	Read      bool      `json:"read"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type NotificationPreference struct {
+	UserID    uint   `json:"-" gorm:"primaryKey"`
+	EventType string `json:"event_type" gorm:"primaryKey"`
+	Enabled   bool   `json:"enabled"`
+}
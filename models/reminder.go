@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Reminder fires a notification about a note at StartsAt and, when RRule is set, at every
+// recurrence of it in Timezone. NextAt is nil once a reminder has no occurrences left.
+type Reminder struct {
+	ID          int        `json:"id"`
+	UserID      uint       `json:"user_id"`
+	NoteID      uint       `json:"note_id"`
+	Message     string     `json:"message"`
+	StartsAt    time.Time  `json:"starts_at"`
+	RRule       string     `json:"rrule" gorm:"column:rrule"`
+	Timezone    string     `json:"timezone"`
+	NextAt      *time.Time `json:"next_at"`
+	LastFiredAt *time.Time `json:"last_fired_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
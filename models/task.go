@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Task is a "- [ ]" checklist item of a note. Line is the 0-based line of the item in the note's content.
+type Task struct {
+	ID        int        `json:"id"`
+	UserID    uint       `json:"user_id"`
+	NoteID    uint       `json:"note_id"`
+	Line      int        `json:"line"`
+	Text      string     `json:"text" gorm:"serializer:encrypted"`
+	Done      bool       `json:"done"`
+	DueDate   *time.Time `json:"due_date" gorm:"type:date"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
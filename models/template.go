@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Template is the skeleton of a new note. Templates with a NotebookID are shared within that
+// notebook, the others are available everywhere for their owner.
+type Template struct {
+	ID         int       `json:"id"`
+	UserID     uint      `json:"user_id"`
+	NotebookID *uint     `json:"notebook_id"`
+	Name       string    `json:"name"`
+	Title      string    `json:"title"`
+	Content    string    `json:"content"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
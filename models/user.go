@@ -1,10 +1,33 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// User roles.
+const (
+	UserRoleUser  = "user"
+	UserRoleAdmin = "admin"
+)
 
 type User struct {
-	gorm.Model `json:"-"`
-	ID         uint   `json:"id" gorm:"primarykey"`
-	Username   string `json:"username" gorm:"unique" binding:"required"`
-	Password   string `json:"password" binding:"required"`
+	gorm.Model        `json:"-"`
+	ID                uint       `json:"id" gorm:"primarykey"`
+	Username          string     `json:"username" gorm:"unique" binding:"required"`
+	Password          string     `json:"password" binding:"required"`
+	Role              string     `json:"role" gorm:"default:user"`
+	Disabled          bool       `json:"disabled"`
+	SessionsRevokedAt *time.Time `json:"-"` // Tokens issued until then are rejected
+	Timezone          string     `json:"timezone" gorm:"default:UTC"`
+	JournalNotebookID *uint      `json:"journal_notebook_id"`
+	JournalTemplateID *uint      `json:"journal_template_id"`
+	CalendarTokenHash *string    `json:"-"`
+}
+
+// TokenRevoked reports whether a token issued at issuedAt was revoked by logging out every
+// session of the user. Tokens only carry whole seconds, so ties count as revoked.
+func (u User) TokenRevoked(issuedAt time.Time) bool {
+	return u.SessionsRevokedAt != nil && !issuedAt.After(*u.SessionsRevokedAt)
 }
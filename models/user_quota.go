@@ -0,0 +1,11 @@
+package models
+
+// UserQuota overrides the default quotas for one user. A nil limit falls back to the
+// default, a limit of 0 means unlimited.
+type UserQuota struct {
+	UserID             uint   `json:"user_id" gorm:"primaryKey"`
+	MaxNotebooks       *int64 `json:"max_notebooks"`
+	MaxNotes           *int64 `json:"max_notes"`
+	MaxAttachmentBytes *int64 `json:"max_attachment_bytes"`
+	MaxExportsPerDay   *int64 `json:"max_exports_per_day"`
+}
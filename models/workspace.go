@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+const (
+	WorkspaceRoleAdmin  = "admin"
+	WorkspaceRoleMember = "member"
+)
+
+// Workspace owns notebooks and, through them, notes. Every member can read and write all
+// notebooks of a workspace; admins manage its members. Each user has a personal workspace,
+// which is the active one unless a request selects another.
+type Workspace struct {
+	ID             uint      `json:"id"`
+	Name           string    `json:"name"`
+	PersonalUserID *uint     `json:"personal_user_id,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type WorkspaceMember struct {
+	WorkspaceID uint      `json:"workspace_id" gorm:"primaryKey"`
+	UserID      uint      `json:"user_id" gorm:"primaryKey"`
+	Role        string    `json:"role"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// WorkspaceInvitation is pending until the invited user accepts or declines it.
+type WorkspaceInvitation struct {
+	ID          uint      `json:"id"`
+	WorkspaceID uint      `json:"workspace_id"`
+	UserID      uint      `json:"user_id"`
+	InvitedBy   *uint     `json:"invited_by"`
+	Role        string    `json:"role"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,80 @@
+package notifications
+
+import (
+	"sync"
+
+	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/models"
+)
+
+// hub keeps track of the open real-time streams per user.
+type hub struct {
+	mu          sync.Mutex
+	subscribers map[uint]map[chan models.Notification]struct{}
+}
+
+var streams = &hub{subscribers: make(map[uint]map[chan models.Notification]struct{})}
+
+// Subscribe registers a new stream for the user. The returned function must be
+// called once the stream is closed.
+func Subscribe(userID uint) (<-chan models.Notification, func()) {
+	ch := make(chan models.Notification, 16)
+
+	streams.mu.Lock()
+	if streams.subscribers[userID] == nil {
+		streams.subscribers[userID] = make(map[chan models.Notification]struct{})
+	}
+	streams.subscribers[userID][ch] = struct{}{}
+	streams.mu.Unlock()
+
+	unsubscribe := func() {
+		streams.mu.Lock()
+		delete(streams.subscribers[userID], ch)
+		if len(streams.subscribers[userID]) == 0 {
+			delete(streams.subscribers, userID)
+		}
+		streams.mu.Unlock()
+	}
+
+	return ch, unsubscribe
+}
+
+// publish pushes the notification to every open stream of its recipient.
+// Slow streams are skipped rather than blocking the caller.
+func publish(notification models.Notification) {
+	streams.mu.Lock()
+	defer streams.mu.Unlock()
+
+	for ch := range streams.subscribers[notification.UserID] {
+		select {
+		case ch <- notification:
+		default:
+		}
+	}
+}
+
+// Enabled reports whether the user wants to be notified about the event type.
+// Event types without a stored preference are enabled by default.
+func Enabled(userID uint, eventType string) bool {
+	var preference models.NotificationPreference
+	result := config.DB.Where("user_id = ? AND event_type = ?", userID, eventType).Limit(1).Find(&preference)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return true
+	}
+	return preference.Enabled
+}
+
+// Notify stores a notification for the user (if their preferences allow it)
+// and pushes it on their real-time stream.
+func Notify(notification models.Notification) error {
+	if !Enabled(notification.UserID, notification.Type) {
+		return nil
+	}
+
+	if err := config.DB.Create(&notification).Error; err != nil {
+		return err
+	}
+
+	publish(notification)
+	return nil
+}
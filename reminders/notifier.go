@@ -0,0 +1,33 @@
+package reminders
+
+import (
+	"sync"
+
+	"noteapp-framework-backend/models"
+)
+
+// Notifier delivers a fired reminder through a channel other than the in-app notifications,
+// such as e-mail or push.
+type Notifier interface {
+	Notify(reminder models.Reminder, note models.Note) error
+}
+
+var (
+	notifiersMu sync.RWMutex
+	notifiers   []Notifier
+)
+
+// RegisterNotifier adds a notifier that is invoked for every fired reminder.
+func RegisterNotifier(notifier Notifier) {
+	notifiersMu.Lock()
+	defer notifiersMu.Unlock()
+
+	notifiers = append(notifiers, notifier)
+}
+
+func registeredNotifiers() []Notifier {
+	notifiersMu.RLock()
+	defer notifiersMu.RUnlock()
+
+	return append([]Notifier(nil), notifiers...)
+}
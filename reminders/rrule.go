@@ -0,0 +1,197 @@
+package reminders
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxOccurrences bounds the expansion of a rule, so a rule that never matches cannot loop forever.
+const maxOccurrences = 100000
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+// Rule is the supported subset of an RFC 5545 recurrence rule:
+// FREQ=DAILY|WEEKLY|MONTHLY|YEARLY with INTERVAL, COUNT, UNTIL and (weekly only) BYDAY.
+type Rule struct {
+	Freq     string
+	Interval int
+	Count    int
+	Until    *time.Time
+	ByDay    []time.Weekday
+}
+
+// ParseRule parses a rule such as "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE". An optional "RRULE:" prefix is accepted.
+func ParseRule(value string) (*Rule, error) {
+	rule := &Rule{Interval: 1}
+
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	for _, part := range strings.Split(value, ";") {
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rule part %q", part)
+		}
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			rule.Freq = strings.ToUpper(val)
+			if !slices.Contains([]string{"DAILY", "WEEKLY", "MONTHLY", "YEARLY"}, rule.Freq) {
+				return nil, fmt.Errorf("unsupported frequency %q", val)
+			}
+		case "INTERVAL":
+			interval, err := strconv.Atoi(val)
+			if err != nil || interval < 1 {
+				return nil, fmt.Errorf("invalid interval %q", val)
+			}
+			rule.Interval = interval
+		case "COUNT":
+			count, err := strconv.Atoi(val)
+			if err != nil || count < 1 {
+				return nil, fmt.Errorf("invalid count %q", val)
+			}
+			rule.Count = count
+		case "UNTIL":
+			until, err := parseUntil(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid until %q", val)
+			}
+			rule.Until = &until
+		case "BYDAY":
+			for _, day := range strings.Split(strings.ToUpper(val), ",") {
+				weekday, ok := weekdays[day]
+				if !ok {
+					return nil, fmt.Errorf("unsupported day %q", day)
+				}
+				if !slices.Contains(rule.ByDay, weekday) {
+					rule.ByDay = append(rule.ByDay, weekday)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("unsupported rule part %q", key)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, errors.New("rule has no frequency")
+	}
+	if rule.Count > 0 && rule.Until != nil {
+		return nil, errors.New("rule cannot have both count and until")
+	}
+	if len(rule.ByDay) > 0 && rule.Freq != "WEEKLY" {
+		return nil, errors.New("by day is only supported for weekly rules")
+	}
+
+	// Days are visited in week order starting on Monday
+	slices.SortFunc(rule.ByDay, func(a, b time.Weekday) int {
+		return (int(a)+6)%7 - (int(b)+6)%7
+	})
+
+	return rule, nil
+}
+
+// Next returns the first occurrence strictly after the given time. Occurrences are counted from
+// the start and keep its wall-clock time in its location, across DST changes.
+// It returns false once the rule has no more occurrences.
+func (r *Rule) Next(start, after time.Time) (time.Time, bool) {
+	occurrences := 0
+	for period := 0; occurrences < maxOccurrences; period++ {
+		for _, occurrence := range r.period(start, period) {
+			if occurrence.Before(start) {
+				continue
+			}
+			occurrences++
+			if r.Count > 0 && occurrences > r.Count {
+				return time.Time{}, false
+			}
+			if r.Until != nil && occurrence.After(*r.Until) {
+				return time.Time{}, false
+			}
+			if occurrence.After(after) {
+				return occurrence, true
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// period returns the occurrences of the n-th interval after the start, in order.
+func (r *Rule) period(start time.Time, n int) []time.Time {
+	year, month, day := start.Date()
+	hour, minute, second := start.Clock()
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, hour, minute, second, 0, start.Location())
+	}
+
+	switch r.Freq {
+	case "DAILY":
+		return []time.Time{at(year, month, day+n*r.Interval)}
+	case "WEEKLY":
+		if len(r.ByDay) == 0 {
+			return []time.Time{at(year, month, day+7*n*r.Interval)}
+		}
+		monday := day - (int(start.Weekday())+6)%7 + 7*n*r.Interval
+		occurrences := make([]time.Time, 0, len(r.ByDay))
+		for _, weekday := range r.ByDay {
+			occurrences = append(occurrences, at(year, month, monday+(int(weekday)+6)%7))
+		}
+		return occurrences
+	case "MONTHLY":
+		// Months without the start's day (e.g. the 31st) are skipped, as in RFC 5545
+		first := at(year, month+time.Month(n*r.Interval), 1)
+		if day > daysIn(first.Year(), first.Month()) {
+			return nil
+		}
+		return []time.Time{at(first.Year(), first.Month(), day)}
+	default:
+		target := year + n*r.Interval
+		if day > daysIn(target, month) {
+			return nil
+		}
+		return []time.Time{at(target, month, day)}
+	}
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func parseUntil(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102"} {
+		if until, err := time.Parse(layout, value); err == nil {
+			if layout == "20060102" {
+				until = until.Add(24*time.Hour - time.Second)
+			}
+			return until, nil
+		}
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// String formats the rule as an RFC 5545 RRULE value, with UNTIL in UTC.
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, weekday := range r.ByDay {
+			days = append(days, strings.ToUpper(weekday.String()[:2]))
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	return strings.Join(parts, ";")
+}
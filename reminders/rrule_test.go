@@ -0,0 +1,62 @@
+package reminders
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRule(t *testing.T) {
+	rule, err := ParseRule("RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=FR,MO")
+	assert.NoError(t, err)
+	assert.Equal(t, "WEEKLY", rule.Freq)
+	assert.Equal(t, 2, rule.Interval)
+	assert.Equal(t, []time.Weekday{time.Monday, time.Friday}, rule.ByDay)
+	assert.Equal(t, "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR", rule.String())
+
+	for _, invalid := range []string{"", "INTERVAL=2", "FREQ=HOURLY", "FREQ=DAILY;COUNT=0", "FREQ=DAILY;BYDAY=MO", "FREQ=DAILY;COUNT=2;UNTIL=20261231"} {
+		_, err := ParseRule(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestRuleNext(t *testing.T) {
+	start := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC) // a Monday
+
+	rule, _ := ParseRule("FREQ=DAILY;COUNT=3")
+	next, ok := rule.Next(start, start)
+	assert.True(t, ok)
+	assert.Equal(t, start.AddDate(0, 0, 1), next)
+	_, ok = rule.Next(start, start.AddDate(0, 0, 2))
+	assert.False(t, ok)
+
+	rule, _ = ParseRule("FREQ=WEEKLY;BYDAY=MO,WE")
+	next, _ = rule.Next(start, start)
+	assert.Equal(t, time.Date(2026, 10, 21, 9, 0, 0, 0, time.UTC), next)
+	next, _ = rule.Next(start, next)
+	assert.Equal(t, time.Date(2026, 10, 26, 9, 0, 0, 0, time.UTC), next)
+
+	// Months without a 31st are skipped
+	rule, _ = ParseRule("FREQ=MONTHLY")
+	endOfMonth := time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC)
+	next, _ = rule.Next(endOfMonth, endOfMonth)
+	assert.Equal(t, time.Date(2026, 3, 31, 9, 0, 0, 0, time.UTC), next)
+
+	rule, _ = ParseRule("FREQ=DAILY;UNTIL=20261020")
+	_, ok = rule.Next(start, start.AddDate(0, 0, 1))
+	assert.False(t, ok)
+}
+
+func TestRuleNextKeepsWallClockAcrossDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone data not available")
+	}
+
+	start := time.Date(2026, 10, 24, 8, 0, 0, 0, berlin)
+	rule, _ := ParseRule("FREQ=DAILY")
+	next, _ := rule.Next(start, start.Add(time.Hour))
+	assert.Equal(t, 8, next.Hour())
+	assert.Equal(t, 25*time.Hour, next.Sub(start))
+}
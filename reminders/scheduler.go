@@ -0,0 +1,160 @@
+package reminders
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"noteapp-framework-backend/config"
+	"noteapp-framework-backend/models"
+	"noteapp-framework-backend/notifications"
+)
+
+const (
+	// schedulerLockKey is the Postgres advisory lock held by the instance firing reminders,
+	// so reminders never fire twice when several backends run.
+	schedulerLockKey = 7240391
+	// batchSize is the maximum number of reminders fired per poll.
+	batchSize = 100
+)
+
+// Schedule sets NextAt to the first occurrence of the reminder after now, or to nil when
+// there is none left.
+func Schedule(reminder *models.Reminder, now time.Time) error {
+	reminder.NextAt = nil
+
+	if reminder.RRule == "" {
+		if reminder.StartsAt.After(now) {
+			next := reminder.StartsAt
+			reminder.NextAt = &next
+		}
+		return nil
+	}
+
+	rule, err := ParseRule(reminder.RRule)
+	if err != nil {
+		return err
+	}
+	location, err := time.LoadLocation(reminder.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	start := reminder.StartsAt.In(location)
+	after := now
+	if start.After(now) {
+		after = start.Add(-time.Nanosecond)
+	}
+	if next, ok := rule.Next(start, after); ok {
+		reminder.NextAt = &next
+	}
+	return nil
+}
+
+// Start runs the scheduler in the background until the context is cancelled. Due reminders
+// are stored in the database, so the ones missed while no backend ran fire on the next start.
+func Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(config.GetReminderPollInterval())
+		defer ticker.Stop()
+
+		for {
+			if err := fireDue(time.Now()); err != nil {
+				log.Printf("Failed to fire reminders: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// fireDue reschedules the reminders due at now and delivers them once the new schedule is committed.
+func fireDue(now time.Time) error {
+	var fired []models.Reminder
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// The lock is released with the transaction; another instance holding it is already firing
+		var locked bool
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", schedulerLockKey).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+
+		var due []models.Reminder
+		if err := tx.Where("next_at <= ?", now).Order("next_at").Limit(batchSize).Find(&due).Error; err != nil {
+			return err
+		}
+
+		for _, reminder := range due {
+			// Occurrences missed while no backend ran fire once, not once each
+			if err := Schedule(&reminder, now); err != nil {
+				log.Printf("Failed to schedule reminder %d: %v", reminder.ID, err)
+			}
+			reminder.LastFiredAt = &now
+
+			if err := tx.Model(&reminder).Select("next_at", "last_fired_at").Updates(&reminder).Error; err != nil {
+				return err
+			}
+			fired = append(fired, reminder)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, reminder := range fired {
+		deliver(reminder)
+	}
+	return nil
+}
+
+// deliver sends the in-app notification of a fired reminder, unless the user turned them off,
+// and invokes the registered notifiers.
+func deliver(reminder models.Reminder) {
+	var note models.Note
+	if err := config.DB.First(&note, reminder.NoteID).Error; err != nil {
+		log.Printf("Failed to load note of reminder %d: %v", reminder.ID, err)
+		return
+	}
+
+	if notifications.Enabled(reminder.UserID, models.NotificationReminder) {
+		noteID := reminder.NoteID
+		err := notifications.Notify(models.Notification{
+			UserID:  reminder.UserID,
+			Type:    models.NotificationReminder,
+			NoteID:  &noteID,
+			Message: reminderMessage(reminder, note),
+		})
+		if err != nil {
+			log.Printf("Failed to notify reminder %d: %v", reminder.ID, err)
+		}
+	}
+
+	for _, notifier := range registeredNotifiers() {
+		if err := notifier.Notify(reminder, note); err != nil {
+			log.Printf("Failed to deliver reminder %d: %v", reminder.ID, err)
+		}
+	}
+}
+
+// reminderMessage is the message of the reminder, else one naming its note. The title of notes in
+// end-to-end encrypted notebooks is ciphertext the server cannot read, so those get a generic one.
+func reminderMessage(reminder models.Reminder, note models.Note) string {
+	switch {
+	case reminder.Message != "":
+		return reminder.Message
+	case note.KeyVersion > 0:
+		return "Reminder"
+	default:
+		return "Reminder: " + note.Title
+	}
+}
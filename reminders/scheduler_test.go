@@ -0,0 +1,18 @@
+package reminders
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"noteapp-framework-backend/models"
+)
+
+func TestReminderMessage(t *testing.T) {
+	note := models.Note{Title: "Dentist"}
+	assert.Equal(t, "Reminder: Dentist", reminderMessage(models.Reminder{}, note))
+	assert.Equal(t, "Call first", reminderMessage(models.Reminder{Message: "Call first"}, note))
+
+	// The title of end-to-end encrypted notes is ciphertext
+	assert.Equal(t, "Reminder", reminderMessage(models.Reminder{}, models.Note{Title: "c2VjcmV0", KeyVersion: 1}))
+}
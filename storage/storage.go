@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// ErrNotFound is returned when no object is stored under a key.
+var ErrNotFound = errors.New("object not found")
+
+// Storage stores binary objects such as attachments under a key.
+type Storage interface {
+	// Put stores size bytes read from r under the key, replacing any existing object.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get reads the object from offset; a negative length reads until its end.
+	Get(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+	// Exists reports whether an object is stored under the key.
+	Exists(ctx context.Context, key string) (bool, error)
+	// Delete removes the object. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Files is the storage used for attachments, set up by Init.
+var Files Storage
+
+// Init configures Files from the environment: STORAGE_DRIVER=local (default) stores objects
+// below STORAGE_PATH, STORAGE_DRIVER=s3 in the S3_BUCKET of an S3-compatible service.
+func Init() {
+	var err error
+	Files, err = fromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure storage: %v", err)
+	}
+}
+
+func fromEnv() (Storage, error) {
+	switch driver := os.Getenv("STORAGE_DRIVER"); driver {
+	case "", "local":
+		root := os.Getenv("STORAGE_PATH")
+		if root == "" {
+			root = "uploads"
+		}
+		return NewLocal(root)
+	case "s3":
+		return NewS3(S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", driver)
+	}
+}